@@ -0,0 +1,309 @@
+package sso
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kohirens/www/storage"
+)
+
+// Account The user of the application, which they can sign in to with an
+// identity from any of the providers linked to it.
+type Account struct {
+	Created time.Time `json:"created"`
+	Email   string    `json:"email"`
+	// EmailVerified Whether the provider verified the email, only verified
+	// emails are used to find the account of a new identity.
+	EmailVerified bool   `json:"email_verified"`
+	FirstName     string `json:"first_name"`
+	ID            string `json:"id"`
+	// Identities The subject of the user at each provider, by the name of
+	// the provider.
+	Identities map[string]string `json:"identities"`
+	LastName   string            `json:"last_name"`
+}
+
+// identity Where the account ID of a provider subject is saved.
+type identity struct {
+	AccountID string `json:"account_id"`
+}
+
+// AccountStore Save accounts to storage, under account/<id>.json, along with
+// a map of each provider subject to its account under
+// identity/<provider>/<subject>.json, and of each verified email to its
+// account under email/<sha256 of the email>.json.
+type AccountStore struct {
+	// Cipher Encrypts the account before it is saved, as it holds the email
+	// and name of the user. Plain JSON is saved when nil.
+	Cipher Cipher
+	Prefix string
+	store  storage.Storage
+}
+
+// NewAccountStore Make an account store, the prefix is put in front of each
+// file in storage, it can be empty.
+func NewAccountStore(store storage.Storage, prefix string) *AccountStore {
+	return &AccountStore{
+		Prefix: prefix,
+		store:  store,
+	}
+}
+
+// Create Make a new account for a provider subject, such as the sub claim of
+// an ID token. Fails when the subject already has an account.
+func (s *AccountStore) Create(provider, subject string, profile *Profile) (*Account, error) {
+	if _, e := s.lookupID(provider, subject); e == nil {
+		return nil, &ErrIdentityLinked{provider, subject}
+	}
+
+	a := &Account{
+		Created:    time.Now().UTC(),
+		ID:         uuid.NewString(),
+		Identities: map[string]string{},
+	}
+
+	if profile != nil {
+		a.Email = profile.Email
+		a.EmailVerified = profile.EmailVerified
+		a.FirstName = profile.GivenName
+		a.LastName = profile.FamilyName
+	}
+
+	if e := s.Save(a); e != nil {
+		return nil, e
+	}
+
+	if e := s.Link(a, provider, subject); e != nil {
+		return nil, e
+	}
+
+	return a, nil
+}
+
+// FindOrCreate Return the account of the identity a user signed in with,
+// making a new account on their first sign in.
+//
+//	When the identity is new, but its verified email belongs to an account,
+//	an ErrLinkRequired is returned instead of making a duplicate account.
+//	Have the user sign in to that account, then call Link, so that no one
+//	can take over an account with an identity they made for its email. An
+//	unverified email is never used to find an account.
+func (s *AccountStore) FindOrCreate(provider string, profile *Profile) (*Account, error) {
+	a, e1 := s.Lookup(provider, profile.Subject)
+	if e1 == nil {
+		return a, nil
+	}
+
+	var noAccount *ErrNoAccount
+	if !errors.As(e1, &noAccount) {
+		return nil, e1
+	}
+
+	if profile.Email != "" && profile.EmailVerified {
+		if existing, e := s.LookupEmail(profile.Email); e == nil {
+			return nil, &ErrLinkRequired{
+				AccountID: existing.ID,
+				Email:     profile.Email,
+				Provider:  provider,
+				Subject:   profile.Subject,
+			}
+		}
+	}
+
+	return s.Create(provider, profile.Subject, profile)
+}
+
+// Link Add an identity from a provider to the account, an account holds one
+// identity per provider. Fails when the subject belongs to another account.
+func (s *AccountStore) Link(a *Account, provider, subject string) error {
+	id, e1 := s.lookupID(provider, subject)
+	if e1 == nil && id != a.ID {
+		return &ErrIdentityLinked{provider, subject}
+	}
+
+	if old, ok := a.Identities[provider]; ok && old != subject {
+		if e := s.store.Remove(s.identityFilename(provider, old)); e != nil {
+			Log.Warnf("%v", e.Error())
+		}
+	}
+
+	data, e2 := seal(nil, &identity{a.ID})
+	if e2 != nil {
+		return e2
+	}
+
+	if e := s.store.Save(s.identityFilename(provider, subject), data); e != nil {
+		return e
+	}
+
+	if a.Identities == nil {
+		a.Identities = map[string]string{}
+	}
+	a.Identities[provider] = subject
+
+	return s.Save(a)
+}
+
+// Load Retrieve an account by its ID.
+func (s *AccountStore) Load(accountID string) (*Account, error) {
+	data, e1 := s.store.Load(s.accountFilename(accountID))
+	if e1 != nil {
+		return nil, &ErrNoAccount{accountID}
+	}
+
+	a := &Account{}
+	if e := open(s.Cipher, data, a, "account"); e != nil {
+		return nil, e
+	}
+
+	return a, nil
+}
+
+// Lookup Retrieve the account a provider subject is linked to.
+func (s *AccountStore) Lookup(provider, subject string) (*Account, error) {
+	id, e1 := s.lookupID(provider, subject)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return s.Load(id)
+}
+
+// LookupEmail Retrieve the account a verified email belongs to.
+func (s *AccountStore) LookupEmail(email string) (*Account, error) {
+	filename := s.emailFilename(email)
+
+	data, e1 := s.store.Load(filename)
+	if e1 != nil {
+		return nil, &ErrNoAccount{email}
+	}
+
+	i := &identity{}
+	if e := open(nil, data, i, filename); e != nil {
+		return nil, e
+	}
+
+	return s.Load(i.AccountID)
+}
+
+// Save Write the account to storage. A verified email is mapped to the
+// account, unless it already belongs to another account. When the verified
+// email changes, or is no longer verified, the map of the old email is
+// removed, so it no longer finds the account.
+func (s *AccountStore) Save(a *Account) error {
+	data, e1 := seal(s.Cipher, a)
+	if e1 != nil {
+		return e1
+	}
+
+	// An account that was never saved has no old email.
+	old, _ := s.Load(a.ID)
+
+	if e := s.store.Save(s.accountFilename(a.ID), data); e != nil {
+		return e
+	}
+
+	if old != nil && old.Email != "" && old.EmailVerified &&
+		(!a.EmailVerified || s.emailFilename(old.Email) != s.emailFilename(a.Email)) {
+		s.removeEmail(old.Email, a.ID)
+	}
+
+	if a.Email == "" || !a.EmailVerified || s.store.Exist(s.emailFilename(a.Email)) {
+		return nil
+	}
+
+	data, e2 := seal(nil, &identity{a.ID})
+	if e2 != nil {
+		return e2
+	}
+
+	return s.store.Save(s.emailFilename(a.Email), data)
+}
+
+// Unlink Remove the identity of a provider from the account. The last
+// identity cannot be removed, or the user could no longer sign in.
+func (s *AccountStore) Unlink(a *Account, provider string) error {
+	subject, ok := a.Identities[provider]
+	if !ok {
+		return nil
+	}
+
+	if len(a.Identities) < 2 {
+		return fmt.Errorf(stderr.UnlinkLastIdentity, provider, a.ID)
+	}
+
+	if e := s.store.Remove(s.identityFilename(provider, subject)); e != nil {
+		return e
+	}
+
+	delete(a.Identities, provider)
+
+	return s.Save(a)
+}
+
+// accountFilename Where the account is saved.
+func (s *AccountStore) accountFilename(accountID string) string {
+	return s.location("account/" + url.PathEscape(accountID))
+}
+
+// emailFilename Where the account ID of a verified email is saved, the email
+// is hashed to keep it out of the name.
+func (s *AccountStore) emailFilename(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return s.location("email/" + hex.EncodeToString(sum[:]))
+}
+
+// identityFilename Where the account ID of a provider subject is saved.
+func (s *AccountStore) identityFilename(provider, subject string) string {
+	return s.location("identity/" + url.PathEscape(provider) + "/" + url.PathEscape(subject))
+}
+
+// location Return the storage location.
+func (s *AccountStore) location(filename string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "/" + filename + ".json"
+	}
+	return filename + ".json"
+}
+
+// removeEmail Remove the map of the email, when it is to the account.
+func (s *AccountStore) removeEmail(email, accountID string) {
+	filename := s.emailFilename(email)
+
+	data, e1 := s.store.Load(filename)
+	if e1 != nil {
+		return
+	}
+
+	i := &identity{}
+	if e := open(nil, data, i, filename); e != nil || i.AccountID != accountID {
+		return
+	}
+
+	if e := s.store.Remove(filename); e != nil {
+		Log.Warnf("%v", e.Error())
+	}
+}
+
+// lookupID Retrieve the ID of the account a provider subject is linked to.
+func (s *AccountStore) lookupID(provider, subject string) (string, error) {
+	filename := s.identityFilename(provider, subject)
+
+	data, e1 := s.store.Load(filename)
+	if e1 != nil {
+		return "", &ErrNoAccount{provider + "/" + subject}
+	}
+
+	i := &identity{}
+	if e := open(nil, data, i, filename); e != nil {
+		return "", e
+	}
+
+	return i.AccountID, nil
+}
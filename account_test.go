@@ -0,0 +1,173 @@
+package sso
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/kohirens/www/storage"
+)
+
+func TestAccountStore(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/test/account", 0777)
+	for _, provider := range []string{"apple", "github", "google"} {
+		_ = os.MkdirAll(tmp+"/test/identity/"+provider, 0777)
+	}
+	store, _ := storage.NewLocalStorage(tmp)
+	c, _ := NewAESGCM(bytes.Repeat([]byte("k"), 32))
+	as := NewAccountStore(store, "test")
+	as.Cipher = c
+
+	a, e1 := as.Create("google", "g1", &Profile{Email: "crowbar@example.com", GivenName: "Crowbar", FamilyName: "Jones"})
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	if _, e := as.Create("google", "g1", nil); e == nil {
+		t.Errorf("Create() made a second account for the same subject")
+	}
+
+	if e := as.Link(a, "apple", "a1"); e != nil {
+		t.Fatal(e)
+	}
+
+	other, _ := as.Create("github", "h1", nil)
+
+	tests := []struct {
+		name     string
+		provider string
+		subject  string
+		want     string
+		wantErr  bool
+	}{
+		{"google", "google", "g1", a.ID, false},
+		{"apple", "apple", "a1", a.ID, false},
+		{"other_account", "github", "h1", other.ID, false},
+		{"unknown", "google", "g2", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := as.Lookup(tt.provider, tt.subject)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Lookup() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.ID != tt.want {
+				t.Errorf("Lookup() = %v, want %v", got.ID, tt.want)
+			}
+		})
+	}
+
+	got, _ := as.Load(a.ID)
+	if got.Email != "crowbar@example.com" || got.Identities["google"] != "g1" || got.Identities["apple"] != "a1" {
+		t.Errorf("Load() = %+v", got)
+	}
+
+	if e := as.Link(other, "google", "g1"); e == nil {
+		t.Errorf("Link() moved an identity linked to another account")
+	}
+
+	if e := as.Unlink(other, "github"); e == nil {
+		t.Errorf("Unlink() removed the last identity of the account")
+	}
+
+	if e := as.Unlink(got, "apple"); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := as.Lookup("apple", "a1"); e == nil {
+		t.Errorf("Lookup() found an identity that was unlinked")
+	}
+}
+
+func TestAccountStore_FindOrCreate(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/account", 0777)
+	_ = os.MkdirAll(tmp+"/email", 0777)
+	for _, provider := range []string{"apple", "github", "google"} {
+		_ = os.MkdirAll(tmp+"/identity/"+provider, 0777)
+	}
+	store, _ := storage.NewLocalStorage(tmp)
+	as := NewAccountStore(store, "")
+
+	existing, e1 := as.FindOrCreate("google", &Profile{Subject: "g1", Email: "Crowbar@example.com", EmailVerified: true})
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	tests := []struct {
+		name        string
+		provider    string
+		profile     *Profile
+		want        string
+		wantLink    bool
+		wantNewAcct bool
+	}{
+		{"returning", "google", &Profile{Subject: "g1", Email: "crowbar@example.com", EmailVerified: true}, existing.ID, false, false},
+		{"verified_email", "apple", &Profile{Subject: "a1", Email: "crowbar@example.com ", EmailVerified: true}, existing.ID, true, false},
+		{"unverified_email", "github", &Profile{Subject: "h1", Email: "crowbar@example.com"}, "", false, true},
+		{"new_email", "apple", &Profile{Subject: "a2", Email: "mallory@example.com", EmailVerified: true}, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := as.FindOrCreate(tt.provider, tt.profile)
+
+			var linkRequired *ErrLinkRequired
+			if errors.As(err, &linkRequired) != tt.wantLink {
+				t.Errorf("FindOrCreate() error = %v, want link required %v", err, tt.wantLink)
+				return
+			}
+
+			if tt.wantLink {
+				if linkRequired.AccountID != tt.want {
+					t.Errorf("FindOrCreate() link to %v, want %v", linkRequired.AccountID, tt.want)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("FindOrCreate() error = %v", err)
+				return
+			}
+
+			if tt.wantNewAcct && got.ID == existing.ID {
+				t.Errorf("FindOrCreate() linked %v to the existing account", tt.profile.Subject)
+			}
+
+			if !tt.wantNewAcct && got.ID != tt.want {
+				t.Errorf("FindOrCreate() = %v, want %v", got.ID, tt.want)
+			}
+		})
+	}
+
+	// Once signed in to the existing account, the identity can be linked.
+	if e := as.Link(existing, "apple", "a1"); e != nil {
+		t.Fatal(e)
+	}
+
+	got, _ := as.FindOrCreate("apple", &Profile{Subject: "a1", Email: "crowbar@example.com", EmailVerified: true})
+	if got == nil || got.ID != existing.ID {
+		t.Errorf("FindOrCreate() after linking = %v, want %v", got, existing.ID)
+	}
+
+	// A changed email finds the account, the old one no longer does.
+	existing.Email = "crowbar@example.org"
+	if e := as.Save(existing); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := as.LookupEmail("crowbar@example.com"); e == nil {
+		t.Errorf("LookupEmail() found the account by its old email")
+	}
+
+	if got, e := as.LookupEmail("crowbar@example.org"); e != nil || got.ID != existing.ID {
+		t.Errorf("LookupEmail() error = %v, want %v", e, existing.ID)
+	}
+}
@@ -0,0 +1,204 @@
+package sso
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+// AuditEventType What happened in an audit event.
+type AuditEventType string
+
+const (
+	AuditDeviceAdded   AuditEventType = "device_added"
+	AuditDeviceRevoked AuditEventType = "device_revoked"
+	AuditLogin         AuditEventType = "login"
+	AuditRefresh       AuditEventType = "refresh"
+	AuditSignOut       AuditEventType = "sign_out"
+	AuditStateMismatch AuditEventType = "state_mismatch"
+	AuditTokenInvalid  AuditEventType = "token_invalid"
+)
+
+// auditDateFormat How the events are partitioned, one file per day in UTC.
+const auditDateFormat = "2006-01-02"
+
+// AuditEvent A record of something that happened during a login.
+type AuditEvent struct {
+	AccountID string `json:"account_id,omitempty"`
+	DeviceID  string `json:"device_id,omitempty"`
+	Provider  string `json:"provider"`
+	// Reason Why it failed, for a failure.
+	Reason string `json:"reason,omitempty"`
+	// Subject The sub claim of the ID token, when there is one.
+	Subject string         `json:"subject,omitempty"`
+	Time    time.Time      `json:"time"`
+	Type    AuditEventType `json:"type"`
+}
+
+// Auditor Record audit events, AuditLog records them to storage.
+type Auditor interface {
+	Record(event *AuditEvent) error
+}
+
+// AuditQuery Which events to return, empty fields match any event.
+type AuditQuery struct {
+	AccountID string
+	// From The earliest time to include, it is required.
+	From    time.Time
+	Subject string
+	// To The latest time to include, defaults to now.
+	To    time.Time
+	Types []AuditEventType
+}
+
+// AuditLog Append audit events to storage as JSON lines, in a file for each
+// day, under audit/<yyyy-mm-dd>.jsonl.
+//
+//	NOTE: This is not an append-only log. Storage cannot append, so an event
+//	is recorded by loading the file of the day, then saving it with the new
+//	line. A lock keeps that safe within a process, so use a single AuditLog
+//	for each storage. Processes that share storage, such as several instances
+//	of a server, overwrite each other's events. Give each one its own Prefix,
+//	or set Audit to an Auditor that sends events to an append-only store.
+type AuditLog struct {
+	Prefix string
+	mu     sync.Mutex
+	store  storage.Storage
+}
+
+// NewAuditLog Make an audit log, the prefix is put in front of each file in
+// storage, it can be empty.
+func NewAuditLog(store storage.Storage, prefix string) *AuditLog {
+	return &AuditLog{
+		Prefix: prefix,
+		store:  store,
+	}
+}
+
+// Query Return the events that match, oldest first. The file of each day
+// from From to To is read, so keep the range short.
+func (a *AuditLog) Query(q *AuditQuery) ([]*AuditEvent, error) {
+	if q.From.IsZero() {
+		return nil, fmt.Errorf("%v", stderr.AuditNoFrom)
+	}
+
+	to := q.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	var events []*AuditEvent
+
+	last := to.UTC().Format(auditDateFormat)
+	for day := q.From.UTC(); ; day = day.AddDate(0, 0, 1) {
+		date := day.Format(auditDateFormat)
+		if date > last {
+			break
+		}
+
+		dayEvents, e1 := a.load(date)
+		if e1 != nil {
+			return nil, e1
+		}
+
+		for _, e := range dayEvents {
+			if e.Time.Before(q.From) || e.Time.After(to) {
+				continue
+			}
+			if q.AccountID != "" && e.AccountID != q.AccountID {
+				continue
+			}
+			if q.Subject != "" && e.Subject != q.Subject {
+				continue
+			}
+			if len(q.Types) > 0 && !slices.Contains(q.Types, e.Type) {
+				continue
+			}
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+// Record Append the event to the file of its day, the time is set to now
+// when it is empty.
+func (a *AuditLog) Record(event *AuditEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	line, e1 := json.Marshal(event)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	filename := a.filename(event.Time.Format(auditDateFormat))
+
+	var data []byte
+	if a.store.Exist(filename) {
+		d, e2 := a.store.Load(filename)
+		if e2 != nil {
+			return fmt.Errorf(stderr.AuditRecord, e2.Error())
+		}
+		data = d
+	}
+
+	data = append(data, line...)
+	data = append(data, '\n')
+
+	if e := a.store.Save(filename, data); e != nil {
+		return fmt.Errorf(stderr.AuditRecord, e.Error())
+	}
+
+	return nil
+}
+
+// filename Where the events of the day are saved.
+func (a *AuditLog) filename(date string) string {
+	if a.Prefix != "" {
+		return a.Prefix + "/audit/" + date + ".jsonl"
+	}
+	return "audit/" + date + ".jsonl"
+}
+
+// load Read the events of the day, there are none when the file does not
+// exist.
+func (a *AuditLog) load(date string) ([]*AuditEvent, error) {
+	filename := a.filename(date)
+	if !a.store.Exist(filename) {
+		return nil, nil
+	}
+
+	data, e1 := a.store.Load(filename)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	var events []*AuditEvent
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		e := &AuditEvent{}
+		if e2 := json.Unmarshal(scanner.Bytes(), e); e2 != nil {
+			return nil, fmt.Errorf(stderr.DecodeJSON, e2.Error())
+		}
+		events = append(events, e)
+	}
+
+	return events, scanner.Err()
+}
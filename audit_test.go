@@ -0,0 +1,64 @@
+package sso
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+func TestAuditLog_Query(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/audit", 0777)
+	store, _ := storage.NewLocalStorage(tmp)
+	al := NewAuditLog(store, "")
+
+	day1 := time.Date(2025, 10, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	day3 := day1.Add(26 * time.Hour)
+
+	for _, e := range []*AuditEvent{
+		{AccountID: "a1", Provider: "google", Subject: "g1", Time: day1, Type: AuditLogin},
+		{AccountID: "a2", Provider: "apple", Subject: "p1", Time: day1.Add(time.Minute), Type: AuditLogin},
+		{AccountID: "a1", Provider: "google", Subject: "g1", Time: day2, Type: AuditTokenInvalid, Reason: "token has expired"},
+		{AccountID: "a1", Provider: "google", Subject: "g1", Time: day3, Type: AuditSignOut},
+	} {
+		if err := al.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !store.Exist("audit/2025-10-01.jsonl") || !store.Exist("audit/2025-10-02.jsonl") {
+		t.Fatalf("Record() did not partition the events by day")
+	}
+
+	tests := []struct {
+		name  string
+		query *AuditQuery
+		want  int
+	}{
+		{"account", &AuditQuery{AccountID: "a1", From: day1, To: day3}, 3},
+		{"time_range", &AuditQuery{AccountID: "a1", From: day2, To: day2}, 1},
+		{"type", &AuditQuery{From: day1, To: day3, Types: []AuditEventType{AuditLogin}}, 2},
+		{"subject", &AuditQuery{Subject: "p1", From: day1, To: day3}, 1},
+		{"no_events", &AuditQuery{From: day1.AddDate(0, 0, -5), To: day1.AddDate(0, 0, -4)}, 0},
+		{"no_from", &AuditQuery{AccountID: "a1", To: day3}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := al.Query(tt.query)
+			if (err != nil) != (tt.want < 0) {
+				t.Errorf("Query() error = %v", err)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if len(got) != tt.want {
+				t.Errorf("Query() = %v events, want %v", len(got), tt.want)
+			}
+		})
+	}
+}
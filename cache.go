@@ -0,0 +1,107 @@
+package sso
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+// DefaultCacheAge How long to keep a downloaded document when the response
+// does not say how long it may be cached.
+var DefaultCacheAge = 24 * time.Hour
+
+// CacheInfo When a cached document was downloaded and when it expires, as
+// told by the HTTP response it came from.
+type CacheInfo struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Expires   time.Time `json:"expires"`
+}
+
+// NewCacheInfo Work out when a document expires from the Cache-Control
+// max-age, or the Expires header of the response. Falls back to the
+// DefaultCacheAge when neither is set.
+func NewCacheInfo(header http.Header, now time.Time) *CacheInfo {
+	ci := &CacheInfo{FetchedAt: now, Expires: now.Add(DefaultCacheAge)}
+
+	if header == nil {
+		return ci
+	}
+
+	if maxAge, ok := cacheMaxAge(header.Get("Cache-Control")); ok {
+		// Age is how long the response already sat in a shared cache.
+		age, _ := strconv.Atoi(header.Get("Age"))
+		ci.Expires = now.Add(time.Duration(maxAge-age) * time.Second)
+		return ci
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		// An invalid date, such as "0", means already expired.
+		t, e1 := http.ParseTime(expires)
+		if e1 != nil {
+			t = now
+		}
+		ci.Expires = t
+	}
+
+	return ci
+}
+
+// Expired Check whether the document has to be downloaded again.
+func (c *CacheInfo) Expired(now time.Time) bool {
+	return !now.Before(c.Expires)
+}
+
+// cacheMaxAge Get the max-age in seconds from a Cache-Control header, no-store
+// and no-cache count as a max-age of 0.
+func cacheMaxAge(cacheControl string) (int, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0, true
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, e := strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+			if e == nil {
+				return seconds, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// cacheInfoFilename Where the cache info of a document is kept in storage.
+func cacheInfoFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".cache.json"
+}
+
+// loadCacheInfo Load the cache info of a document from storage.
+func loadCacheInfo(store storage.Storage, filename string) (*CacheInfo, error) {
+	data, e1 := store.Load(cacheInfoFilename(filename))
+	if e1 != nil {
+		return nil, e1
+	}
+
+	ci := &CacheInfo{}
+	if e := json.Unmarshal(data, ci); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return ci, nil
+}
+
+// saveCacheInfo Save the cache info of a document alongside it in storage.
+func saveCacheInfo(store storage.Storage, filename string, ci *CacheInfo) error {
+	data, e1 := json.Marshal(ci)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	return store.Save(cacheInfoFilename(filename), data)
+}
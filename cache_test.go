@@ -0,0 +1,122 @@
+package sso
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kohirens/stdlib/test"
+	"github.com/kohirens/www/storage"
+)
+
+func TestNewCacheInfo(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Time
+	}{
+		{"no_header", nil, now.Add(DefaultCacheAge)},
+		{"max_age", http.Header{"Cache-Control": {"public, max-age=21600, must-revalidate"}}, now.Add(6 * time.Hour)},
+		{"max_age_with_age", http.Header{"Cache-Control": {"max-age=3600"}, "Age": {"600"}}, now.Add(50 * time.Minute)},
+		{"no_store", http.Header{"Cache-Control": {"no-store"}}, now},
+		{"expires", http.Header{"Expires": {"Wed, 01 Oct 2025 18:00:00 GMT"}}, now.Add(6 * time.Hour)},
+		{"max_age_over_expires", http.Header{"Cache-Control": {"max-age=60"}, "Expires": {"Wed, 01 Oct 2025 18:00:00 GMT"}}, now.Add(time.Minute)},
+		{"invalid_expires", http.Header{"Expires": {"0"}}, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewCacheInfo(tt.header, now)
+			if !got.Expires.Equal(tt.want) {
+				t.Errorf("NewCacheInfo() expires = %v, want %v", got.Expires, tt.want)
+			}
+		})
+	}
+}
+
+// closeCounter A response body that counts when it is closed.
+type closeCounter struct {
+	io.Reader
+	closed *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestLoadDocument(t *testing.T) {
+	tests := []struct {
+		name          string
+		cached        string
+		cacheInfo     *CacheInfo
+		status        int
+		want          string
+		wantDownloads int
+		wantErr       bool
+	}{
+		{"fresh_cache", "cached", &CacheInfo{Expires: time.Now().Add(time.Hour)}, 200, "cached", 0, false},
+		{"expired_cache", "cached", &CacheInfo{Expires: time.Now().Add(-time.Hour)}, 200, "downloaded", 1, false},
+		{"no_cache_info", "cached", nil, 200, "downloaded", 1, false},
+		{"stale_if_error", "cached", &CacheInfo{Expires: time.Now().Add(-time.Hour)}, 503, "cached", 3, false},
+		{"no_cache", "", nil, 200, "downloaded", 1, false},
+		{"no_cache_error", "", nil, 503, "", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := storage.NewLocalStorage(t.TempDir())
+			if tt.cached != "" {
+				_ = store.Save("doc.json", []byte(tt.cached))
+			}
+			if tt.cacheInfo != nil {
+				_ = saveCacheInfo(store, "doc.json", tt.cacheInfo)
+			}
+
+			downloads, closed := 0, 0
+			client := &test.MockHttpClient{
+				DoHandler: func(r *http.Request) (*http.Response, error) {
+					downloads++
+					return &http.Response{
+						StatusCode: tt.status,
+						Header:     http.Header{"Cache-Control": {"max-age=3600"}},
+						Body:       closeCounter{bytes.NewBufferString("downloaded"), &closed},
+					}, nil
+				},
+			}
+
+			var got string
+			err := LoadDocument(client, store, "doc.json", "https://idp.example.com/doc", func(data []byte) error {
+				if len(data) == 0 {
+					return fmt.Errorf("empty")
+				}
+				got = string(data)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadDocument() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want || downloads != tt.wantDownloads {
+				t.Errorf("LoadDocument() = %v with %v downloads, want %v with %v", got, downloads, tt.want, tt.wantDownloads)
+			}
+
+			if closed != downloads {
+				t.Errorf("LoadDocument() closed %v of %v response bodies", closed, downloads)
+			}
+
+			if tt.wantErr || tt.want != "downloaded" {
+				return
+			}
+
+			ci, e1 := loadCacheInfo(store, "doc.json")
+			if e1 != nil || ci.Expired(time.Now().Add(59*time.Minute)) {
+				t.Errorf("LoadDocument() did not save the cache info from the response, got %v, %v", ci, e1)
+			}
+		})
+	}
+}
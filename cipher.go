@@ -0,0 +1,128 @@
+package sso
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+)
+
+// Cipher Encrypt data before it is saved to storage, and decrypt it after it
+// is loaded. The gpg.Capsule from github.com/kohirens/www/gpg is a Cipher
+// that uses the OpenPGP keys of the application.
+type Cipher interface {
+	Decrypt(data []byte) ([]byte, error)
+	Encrypt(data []byte) ([]byte, error)
+}
+
+// AESGCM A Cipher using AES in Galois/Counter Mode with a secret key, for
+// when the application has no OpenPGP keys.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM Make an AES-GCM Cipher, the key must be 16, 24, or 32 bytes to
+// select AES-128, AES-192, or AES-256.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, e1 := aes.NewCipher(key)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.CipherKey, e1.Error())
+	}
+
+	aead, e2 := cipher.NewGCM(block)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.CipherKey, e2.Error())
+	}
+
+	return &AESGCM{aead}, nil
+}
+
+// Decrypt Open data sealed by Encrypt.
+func (c *AESGCM) Decrypt(data []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return nil, fmt.Errorf(stderr.Decrypt, "data is too short")
+	}
+
+	plaintext, e1 := c.aead.Open(nil, data[:size], data[size:], nil)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.Decrypt, e1.Error())
+	}
+
+	return plaintext, nil
+}
+
+// Encrypt Seal the data with a random nonce, which is put in front of the
+// result.
+func (c *AESGCM) Encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return nil, fmt.Errorf(stderr.Random, e.Error())
+	}
+
+	return c.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// SealLoginInfo Encode the login info as JSON, then encrypt it when a cipher
+// is set.
+func SealLoginInfo(c Cipher, li *LoginInfo) ([]byte, error) {
+	return seal(c, li)
+}
+
+// OpenLoginInfo Decrypt the login info when a cipher is set, then decode it.
+//
+//	Login info saved before encryption was turned on is plain JSON, it is
+//	read as is, and will be encrypted the next time it is saved.
+func OpenLoginInfo(c Cipher, data []byte) (*LoginInfo, error) {
+	li := &LoginInfo{}
+	if e := open(c, data, li, "login info"); e != nil {
+		return nil, e
+	}
+
+	return li, nil
+}
+
+// open Decrypt the data when a cipher is set, then decode it into v, data
+// that is plain JSON is decoded as is.
+func open(c Cipher, data []byte, v interface{}, name string) error {
+	if c == nil || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		e1 := json.Unmarshal(data, v)
+		if e1 == nil {
+			if c != nil {
+				Log.Infof(stdout.Plaintext, name)
+			}
+			return nil
+		}
+
+		if c == nil {
+			return fmt.Errorf(stderr.DecodeJSON, e1.Error())
+		}
+	}
+
+	plaintext, e2 := c.Decrypt(data)
+	if e2 != nil {
+		return e2
+	}
+
+	if e := json.Unmarshal(plaintext, v); e != nil {
+		return fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return nil
+}
+
+// seal Encode v as JSON, then encrypt it when a cipher is set.
+func seal(c Cipher, v interface{}) ([]byte, error) {
+	data, e1 := json.Marshal(v)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	if c == nil {
+		return data, nil
+	}
+
+	return c.Encrypt(data)
+}
@@ -0,0 +1,73 @@
+package sso
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpenLoginInfo(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), 32)
+	c, _ := NewAESGCM(key)
+	other, _ := NewAESGCM(bytes.Repeat([]byte("o"), 32))
+
+	li := &LoginInfo{AccountID: "a1", Email: "crowbar@example.com", RefreshToken: "r1"}
+	plain, _ := SealLoginInfo(nil, li)
+	sealed, e1 := SealLoginInfo(c, li)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	if bytes.Contains(sealed, []byte("r1")) || bytes.Contains(sealed, []byte("crowbar")) {
+		t.Fatalf("SealLoginInfo() did not encrypt the login info: %s", sealed)
+	}
+
+	tests := []struct {
+		name    string
+		cipher  Cipher
+		data    []byte
+		wantErr bool
+	}{
+		{"no_cipher", nil, plain, false},
+		{"encrypted", c, sealed, false},
+		{"legacy_plaintext", c, plain, false},
+		{"wrong_key", other, sealed, true},
+		{"encrypted_no_cipher", nil, sealed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenLoginInfo(tt.cipher, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OpenLoginInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.AccountID != li.AccountID || got.Email != li.Email || got.RefreshToken != li.RefreshToken {
+				t.Errorf("OpenLoginInfo() = %+v, want %+v", got, li)
+			}
+		})
+	}
+}
+
+func TestNewAESGCM(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr bool
+	}{
+		{"aes_128", bytes.Repeat([]byte("k"), 16), false},
+		{"aes_256", bytes.Repeat([]byte("k"), 32), false},
+		{"bad_size", []byte("short"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAESGCM(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAESGCM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
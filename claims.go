@@ -0,0 +1,99 @@
+package sso
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	jwt "github.com/kohirens/json-web-token"
+)
+
+// IDTokenClaims The claims of an ID token used by the library, see:
+// https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type IDTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      Audience `json:"aud"`
+	Expires       int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified"`
+	Hd            string   `json:"hd,omitempty"`
+	Nonce         string   `json:"nonce,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Picture       string   `json:"picture,omitempty"`
+	// AuthorizedParty The azp claim, the client the token was issued to,
+	// when there are several audiences.
+	AuthorizedParty string `json:"azp,omitempty"`
+}
+
+// Audience The aud claim, which may be a single string or an array of
+// strings.
+type Audience []string
+
+// UnmarshalJSON Decode the aud claim from either form.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if e := json.Unmarshal(data, &one); e == nil {
+		*a = Audience{one}
+		return nil
+	}
+
+	var many []string
+	if e := json.Unmarshal(data, &many); e != nil {
+		return e
+	}
+
+	*a = many
+
+	return nil
+}
+
+// Contains Check the client ID is one of the audiences.
+func (a Audience) Contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UnmarshalJSON Decode the claims, accepting email_verified as a string, as
+// some providers, such as Apple, send "true" rather than true.
+func (c *IDTokenClaims) UnmarshalJSON(data []byte) error {
+	type claims IDTokenClaims
+	aux := &struct {
+		*claims
+		EmailVerified interface{} `json:"email_verified"`
+	}{claims: (*claims)(c)}
+
+	if e := json.Unmarshal(data, aux); e != nil {
+		return e
+	}
+
+	switch v := aux.EmailVerified.(type) {
+	case bool:
+		c.EmailVerified = v
+	case string:
+		c.EmailVerified, _ = strconv.ParseBool(v)
+	}
+
+	return nil
+}
+
+// LoadIDTokenClaims Decode the claims from the payload of an ID token.
+func LoadIDTokenClaims(payload jwt.ClaimSet) (*IDTokenClaims, error) {
+	data, e1 := json.Marshal(payload)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	claims := &IDTokenClaims{}
+	if e := json.Unmarshal(data, claims); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,142 @@
+package sso
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RememberCookieName The default name of the cookie, the __Host- prefix has
+// the browser reject it unless it is Secure, for the whole site, and without
+// a Domain.
+const RememberCookieName = "__Host-sso_remember"
+
+// RememberCookieMaxAge How long the browser keeps the cookie by default.
+const RememberCookieMaxAge = 30 * 24 * time.Hour
+
+// Remember What is sealed in the "remember this account" cookie, so that a
+// returning client can be recognized after their session times out.
+type Remember struct {
+	AccountID string `json:"account_id"`
+	DeviceID  string `json:"device_id"`
+	IssuedAt  int64  `json:"iat"`
+}
+
+// CookieSealer Seal and open the "remember this account" cookie.
+//
+//	The first cipher encrypts new cookies, the rest are only used to open
+//	cookies sealed before the keys were rotated. To rotate, put the new key
+//	first and keep the old key after it for at least MaxAge.
+type CookieSealer struct {
+	Ciphers []Cipher
+	// Domain Leave empty to keep the cookie on the host that set it, it must
+	// be empty when the name has the __Host- prefix.
+	Domain string
+	MaxAge time.Duration
+	Name   string
+}
+
+// NewCookieSealer Make a cookie sealer with the default name and max age.
+func NewCookieSealer(ciphers ...Cipher) *CookieSealer {
+	return &CookieSealer{
+		Ciphers: ciphers,
+		MaxAge:  RememberCookieMaxAge,
+		Name:    RememberCookieName,
+	}
+}
+
+// Clear Return a cookie that has the browser delete the cookie, for when the
+// client signs out.
+func (s *CookieSealer) Clear() *http.Cookie {
+	c := s.cookie("")
+	c.MaxAge = -1
+	c.Expires = time.Unix(0, 0)
+
+	return c
+}
+
+// Open Read the cookie from the request, then decrypt it.
+func (s *CookieSealer) Open(r *http.Request) (*Remember, error) {
+	c, e1 := r.Cookie(s.Name)
+	if e1 != nil {
+		return nil, &ErrNoCookie{s.Name}
+	}
+
+	return s.OpenValue(c.Value)
+}
+
+// OpenValue Decrypt the value of the cookie, trying each cipher in turn, and
+// verify it has not expired.
+func (s *CookieSealer) OpenValue(value string) (*Remember, error) {
+	sealed, e1 := base64.RawURLEncoding.DecodeString(value)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.DecodeBase64URL, e1.Error())
+	}
+
+	var data []byte
+	for _, c := range s.Ciphers {
+		if d, e := c.Decrypt(sealed); e == nil {
+			data = d
+			break
+		}
+	}
+
+	if data == nil {
+		return nil, &ErrCookieOpen{s.Name}
+	}
+
+	rm := &Remember{}
+	if e := json.Unmarshal(data, rm); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	if time.Now().After(time.Unix(rm.IssuedAt, 0).Add(s.MaxAge)) {
+		return nil, &ErrCookieExpired{s.Name}
+	}
+
+	return rm, nil
+}
+
+// Seal Encrypt the account ID and device ID with the first cipher, into a
+// cookie to set on the response.
+func (s *CookieSealer) Seal(accountID, deviceID string) (*http.Cookie, error) {
+	if len(s.Ciphers) < 1 {
+		return nil, fmt.Errorf("%v", stderr.NoCookieCipher)
+	}
+
+	data, e1 := json.Marshal(&Remember{
+		AccountID: accountID,
+		DeviceID:  deviceID,
+		IssuedAt:  time.Now().Unix(),
+	})
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	sealed, e2 := s.Ciphers[0].Encrypt(data)
+	if e2 != nil {
+		return nil, e2
+	}
+
+	return s.cookie(base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+// cookie Make the cookie with the attributes to keep it from scripts, plain
+// HTTP, and cross-site requests.
+func (s *CookieSealer) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Domain:   s.Domain,
+		Expires:  time.Now().Add(s.MaxAge),
+		HttpOnly: true,
+		MaxAge:   int(s.MaxAge.Seconds()),
+		Name:     s.Name,
+		Path:     "/",
+		// Lax, so it is sent when the provider redirects back to the
+		// callback, which is a cross-site navigation.
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+		Value:    value,
+	}
+}
@@ -0,0 +1,65 @@
+package sso
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCookieSealer(t *testing.T) {
+	oldKey, _ := NewAESGCM(bytes.Repeat([]byte("o"), 32))
+	newKey, _ := NewAESGCM(bytes.Repeat([]byte("n"), 32))
+	otherKey, _ := NewAESGCM(bytes.Repeat([]byte("x"), 32))
+
+	expired, _ := json.Marshal(&Remember{AccountID: "a1", DeviceID: "d1", IssuedAt: time.Now().Add(-RememberCookieMaxAge - time.Minute).Unix()})
+	expiredSealed, _ := newKey.Encrypt(expired)
+
+	tests := []struct {
+		name    string
+		sealer  *CookieSealer
+		opener  *CookieSealer
+		value   string
+		wantErr bool
+	}{
+		{"same_key", NewCookieSealer(newKey), NewCookieSealer(newKey), "", false},
+		{"rotated_key", NewCookieSealer(oldKey), NewCookieSealer(newKey, oldKey), "", false},
+		{"unknown_key", NewCookieSealer(otherKey), NewCookieSealer(newKey, oldKey), "", true},
+		{"expired", nil, NewCookieSealer(newKey), base64.RawURLEncoding.EncodeToString(expiredSealed), true},
+		{"tampered", nil, NewCookieSealer(newKey), "AAAA", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "https://app.example.com/sign-in", nil)
+			if tt.sealer != nil {
+				c, e1 := tt.sealer.Seal("a1", "d1")
+				if e1 != nil {
+					t.Fatal(e1)
+				}
+
+				if !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+					t.Errorf("Seal() cookie attributes = %+v", c)
+				}
+				r.AddCookie(c)
+			} else {
+				r.AddCookie(&http.Cookie{Name: RememberCookieName, Value: tt.value})
+			}
+
+			got, err := tt.opener.Open(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Open() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.AccountID != "a1" || got.DeviceID != "d1" {
+				t.Errorf("Open() = %+v, want account a1 and device d1", got)
+			}
+		})
+	}
+}
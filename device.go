@@ -1,30 +1,158 @@
 package sso
 
 import (
+	"crypto/hmac"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/mileusna/useragent"
-	"time"
 )
 
+// DeviceCookieName The default name of the cookie that holds the device ID.
+const DeviceCookieName = "__Host-sso_device"
+
+// DeviceCookieMaxAge How long the browser keeps the device ID, browsers cap
+// cookies at 400 days.
+const DeviceCookieMaxAge = 400 * 24 * time.Hour
+
 type Device struct {
-	ID           string               `json:"id"`
-	LastActivity time.Time            `json:"last_activity"`
-	OIDCProvider string               `json:"oidc_provider"`
-	SessionID    string               `json:"session_id"`
-	UserAgent    *useragent.UserAgent `json:"user_agent"`
+	// ID A random value issued to the browser once, see DeviceCookie.
+	ID string `json:"id"`
+	// IPAddress Where the device last signed in from, when the application
+	// sets it, see Login.SetIPAddress.
+	IPAddress string `json:"ip_address,omitempty"`
+	// Label A name the user gave the device, such as "Work laptop".
+	Label        string    `json:"label,omitempty"`
+	LastActivity time.Time `json:"last_activity"`
+	OIDCProvider string    `json:"oidc_provider"`
+	SessionID    string    `json:"session_id"`
+	// Trusted Whether the user marked the device as one they own.
+	Trusted bool `json:"trusted"`
+	// UserAgent Only used as a signal to assess the risk of the device, it
+	// does not identify it.
+	UserAgent *useragent.UserAgent `json:"user_agent"`
 }
 
+// DeviceCookie Issue a device ID to a browser in a signed cookie, so that it
+// keeps the same ID across sessions and browser updates.
+type DeviceCookie struct {
+	Key    []byte
+	MaxAge time.Duration
+	Name   string
+}
+
+// Authenticated Whether the session is the one bound to the device, it is
+// false once the device is revoked or signed out, until it signs in again.
+func (d *Device) Authenticated(sessionID string) bool {
+	return d.SessionID != "" && d.SessionID == sessionID
+}
+
+// DeviceId Derive an ID from the user agent.
+//
+// Deprecated: Browsers with the same user agent share the ID, and it changes
+// when the browser updates. Use NewDeviceID with a DeviceCookie instead, this
+// is only kept to look up devices saved before.
 func DeviceId(userAgent []byte) string {
 	id := uuid.NewSHA1(uuid.NameSpaceOID, userAgent)
 	return id.String()
 }
 
-func NewDevice(userAgent string, sessionID, oidcProvider string) *Device {
+// NewDevice Make a device with a new random ID.
+func NewDevice(userAgent, sessionID, oidcProvider string) *Device {
+	return NewDeviceWithID(NewDeviceID(), userAgent, sessionID, oidcProvider)
+}
+
+// NewDeviceID Generate a random device ID.
+func NewDeviceID() string {
+	return uuid.New().String()
+}
+
+// NewDeviceWithID Make a device with the ID issued to the browser, see
+// DeviceCookie, a new ID is made when it is empty.
+func NewDeviceWithID(deviceID, userAgent, sessionID, oidcProvider string) *Device {
+	if deviceID == "" {
+		deviceID = NewDeviceID()
+	}
+
 	ua := useragent.Parse(userAgent)
 	return &Device{
-		ID:           DeviceId([]byte(userAgent)),
+		ID:           deviceID,
+		LastActivity: time.Now(),
 		OIDCProvider: oidcProvider,
 		SessionID:    sessionID,
 		UserAgent:    &ua,
 	}
 }
+
+// NewDeviceCookie Make a device cookie with the default name and max age, the
+// key signs the cookie so that a device ID cannot be forged.
+func NewDeviceCookie(key []byte) *DeviceCookie {
+	return &DeviceCookie{
+		Key:    key,
+		MaxAge: DeviceCookieMaxAge,
+		Name:   DeviceCookieName,
+	}
+}
+
+// ID Return the device ID from the request, issuing a new one on the
+// response when the browser has none, or its cookie is not valid.
+func (dc *DeviceCookie) ID(w http.ResponseWriter, r *http.Request) (string, error) {
+	deviceID, e1 := dc.Read(r)
+	if e1 == nil {
+		return deviceID, nil
+	}
+
+	c, e2 := dc.Issue()
+	if e2 != nil {
+		return "", e2
+	}
+
+	http.SetCookie(w, c)
+
+	deviceID, _, _ = strings.Cut(c.Value, ".")
+
+	return deviceID, nil
+}
+
+// Issue Make a cookie with a new device ID.
+func (dc *DeviceCookie) Issue() (*http.Cookie, error) {
+	if len(dc.Key) == 0 {
+		return nil, fmt.Errorf("%v", stderr.NoDeviceKey)
+	}
+
+	deviceID := NewDeviceID()
+
+	return &http.Cookie{
+		Expires:  time.Now().Add(dc.MaxAge),
+		HttpOnly: true,
+		MaxAge:   int(dc.MaxAge.Seconds()),
+		Name:     dc.Name,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+		Value:    deviceID + "." + sign(dc.Key, deviceID),
+	}, nil
+}
+
+// Read Return the device ID from the cookie in the request, after verifying
+// its signature.
+func (dc *DeviceCookie) Read(r *http.Request) (string, error) {
+	if len(dc.Key) == 0 {
+		return "", fmt.Errorf("%v", stderr.NoDeviceKey)
+	}
+
+	c, e1 := r.Cookie(dc.Name)
+	if e1 != nil {
+		return "", &ErrNoCookie{dc.Name}
+	}
+
+	deviceID, sig, found := strings.Cut(c.Value, ".")
+	if !found || deviceID == "" || !hmac.Equal([]byte(sig), []byte(sign(dc.Key, deviceID))) {
+		return "", &ErrDeviceSignature{dc.Name}
+	}
+
+	return deviceID, nil
+}
@@ -0,0 +1,67 @@
+package sso
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeviceCookie_ID(t *testing.T) {
+	key := []byte("device-cookie-key")
+	issued, _ := NewDeviceCookie(key).Issue()
+	forged, _ := NewDeviceCookie([]byte("other-key")).Issue()
+
+	tests := []struct {
+		name      string
+		cookie    *http.Cookie
+		wantSame  bool
+		wantIssue bool
+	}{
+		{"no_cookie", nil, false, true},
+		{"issued", issued, true, false},
+		{"forged", forged, false, true},
+		{"tampered", &http.Cookie{Name: DeviceCookieName, Value: "d1." + sign(key, "d2")}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "https://app.example.com/sign-in", nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+
+			got, err := NewDeviceCookie(key).ID(w, r)
+			if err != nil || got == "" {
+				t.Errorf("ID() = %q, error = %v", got, err)
+				return
+			}
+
+			if tt.cookie != nil && (got == tt.cookie.Value[:36]) != tt.wantSame {
+				t.Errorf("ID() = %v, want the ID of the cookie %v", got, tt.wantSame)
+			}
+
+			cookies := w.Result().Cookies()
+			if (len(cookies) > 0) != tt.wantIssue {
+				t.Errorf("ID() issued a cookie = %v, want %v", len(cookies) > 0, tt.wantIssue)
+			}
+
+			if len(cookies) > 0 && (!cookies[0].Secure || !cookies[0].HttpOnly || cookies[0].Path != "/") {
+				t.Errorf("ID() cookie attributes = %+v", cookies[0])
+			}
+		})
+	}
+}
+
+func TestNewDevice(t *testing.T) {
+	ua := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+	a := NewDevice(ua, "s1", "google")
+	b := NewDevice(ua, "s2", "google")
+	if a.ID == b.ID {
+		t.Errorf("NewDevice() gave two browsers with the same user agent the ID %v", a.ID)
+	}
+
+	if got := NewDeviceWithID("d1", ua, "s1", "google"); got.ID != "d1" {
+		t.Errorf("NewDeviceWithID() = %v, want d1", got.ID)
+	}
+}
@@ -0,0 +1,180 @@
+package sso
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// GrantTypeDeviceCode The grant type to exchange a device code for a token.
+const GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Errors the token endpoint responds with while polling, see:
+// https://www.rfc-editor.org/rfc/rfc8628#section-3.5
+const (
+	deviceAccessDenied  = "access_denied"
+	deviceExpiredToken  = "expired_token"
+	devicePending       = "authorization_pending"
+	deviceSlowDown      = "slow_down"
+	deviceDefaultPoll   = 5 * time.Second
+	deviceSlowDownExtra = 5 * time.Second
+)
+
+// DeviceAuthorization The codes a device gets to sign in on another device
+// that has a browser, see:
+// https://www.rfc-editor.org/rfc/rfc8628#section-3.2
+type DeviceAuthorization struct {
+	// DeviceCode Sent to the token endpoint, never shown to the user.
+	DeviceCode string `json:"device_code"`
+	// UserCode Show to the user, for them to enter at the VerificationURI.
+	UserCode string `json:"user_code"`
+	// VerificationURI Show to the user, where they enter the UserCode.
+	VerificationURI string `json:"verification_uri"`
+	// VerificationURIComplete Optional, the VerificationURI with the
+	// UserCode included, for showing as a QR code.
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	// ExpiresIn The lifetime of the codes in seconds.
+	ExpiresIn int `json:"expires_in"`
+	// Interval The least number of seconds to wait between polls.
+	Interval int `json:"interval,omitempty"`
+	// Expires When the codes expire.
+	Expires time.Time `json:"-"`
+}
+
+// tokenError The error the token endpoint responds with.
+type tokenError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// after Wait for the duration, replaced in tests.
+var after = time.After
+
+// StartDeviceAuthorization Ask the device authorization endpoint for a device
+// code and user code. The request body holds the client ID, scope, and any
+// client credentials the provider wants.
+func StartDeviceAuthorization(client HttpClient, uri, reqBody string, headers http.Header) (*DeviceAuthorization, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("%v", stderr.NoDeviceAuthURI)
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, e1 := SendWithRetry(client, "POST", uri, []byte(reqBody), headers, http.StatusOK, 3)
+	if res == nil {
+		return nil, fmt.Errorf(stderr.Response, e1)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	data, e2 := io.ReadAll(res.Body)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ReadResponse, e2.Error())
+	}
+
+	// Google names the verification URI verification_url.
+	da := &struct {
+		DeviceAuthorization
+		VerificationURL string `json:"verification_url"`
+	}{}
+	if e := json.Unmarshal(data, da); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	if da.VerificationURI == "" {
+		da.VerificationURI = da.VerificationURL
+	}
+	da.Expires = time.Now().Add(time.Duration(da.ExpiresIn) * time.Second)
+
+	return &da.DeviceAuthorization, nil
+}
+
+// PollDeviceToken Poll the token endpoint until the user approves the device,
+// then return the token response. Waits the interval between polls, adding
+// 5 seconds each time the provider says to slow down. Stops when the user
+// denies access, the codes expire, or the context is done.
+//
+//	The request body holds the device code, grant type, and any client
+//	credentials the provider wants.
+func PollDeviceToken(ctx context.Context, client HttpClient, uri, reqBody string, headers http.Header, da *DeviceAuthorization) ([]byte, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("%v", stderr.NoTokenURI)
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	headers.Set("Accept", "application/json")
+
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = deviceDefaultPoll
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-after(interval):
+		}
+
+		if !da.Expires.IsZero() && time.Now().After(da.Expires) {
+			return nil, &ErrDeviceCodeExpired{}
+		}
+
+		code, data, e1 := pollOnce(ctx, client, uri, reqBody, headers)
+		if e1 != nil {
+			return nil, e1
+		}
+
+		te := &tokenError{}
+		_ = json.Unmarshal(data, te)
+
+		if code == http.StatusOK && te.Error == "" {
+			return data, nil
+		}
+
+		switch te.Error {
+		case devicePending:
+			Log.Dbugf(stdout.DevicePending, da.UserCode)
+		case deviceSlowDown:
+			interval += deviceSlowDownExtra
+		case deviceAccessDenied:
+			return nil, &ErrDeviceAccessDenied{}
+		case deviceExpiredToken:
+			return nil, &ErrDeviceCodeExpired{}
+		default:
+			return nil, fmt.Errorf(stderr.DeviceToken, code, string(data))
+		}
+	}
+}
+
+// pollOnce Make a single token request, the status code is checked by the
+// caller, as the token endpoint uses them while the user has yet to approve.
+func pollOnce(ctx context.Context, client HttpClient, uri, reqBody string, headers http.Header) (int, []byte, error) {
+	req, e1 := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader([]byte(reqBody)))
+	if e1 != nil {
+		return 0, nil, fmt.Errorf(stderr.BuildRequest, e1.Error())
+	}
+	req.Header = headers
+
+	res, e2 := client.Do(req)
+	if e2 != nil {
+		return 0, nil, fmt.Errorf(stderr.Response, e2.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	data, e3 := io.ReadAll(res.Body)
+	if e3 != nil {
+		return 0, nil, fmt.Errorf(stderr.ReadResponse, e3.Error())
+	}
+
+	return res.StatusCode, data, nil
+}
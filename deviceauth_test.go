@@ -0,0 +1,123 @@
+package sso
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kohirens/stdlib/test"
+)
+
+func TestStartDeviceAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"no_endpoint", "", "", "", true},
+		{"verification_uri", "https://idp.example.com/device", `{"device_code":"d1","user_code":"WDJB-MJHT","verification_uri":"https://idp.example.com/activate","expires_in":1800}`, "https://idp.example.com/activate", false},
+		{"verification_url", "https://idp.example.com/device", `{"device_code":"d1","user_code":"WDJB-MJHT","verification_url":"https://www.google.com/device","expires_in":1800}`, "https://www.google.com/device", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &test.MockHttpClient{
+				DoHandler: func(r *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(tt.body))}, nil
+				},
+			}
+
+			got, err := StartDeviceAuthorization(client, tt.uri, "client_id=c1&scope=openid", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StartDeviceAuthorization() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.VerificationURI != tt.want || got.UserCode != "WDJB-MJHT" || got.Expires.IsZero() {
+				t.Errorf("StartDeviceAuthorization() = %+v, want verification URI %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollDeviceToken(t *testing.T) {
+	pending := `{"error":"authorization_pending"}`
+	slowDown := `{"error":"slow_down"}`
+	token := `{"access_token":"a1","id_token":"i1"}`
+
+	tests := []struct {
+		name      string
+		responses []string
+		want      string
+		wantWaits []time.Duration
+		wantErr   bool
+	}{
+		{"approved", []string{pending, pending, token}, token, []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second}, false},
+		{"slow_down", []string{slowDown, token}, token, []time.Duration{5 * time.Second, 10 * time.Second}, false},
+		{"access_denied", []string{pending, `{"error":"access_denied"}`}, "", []time.Duration{5 * time.Second, 5 * time.Second}, true},
+		{"expired_token", []string{`{"error":"expired_token"}`}, "", []time.Duration{5 * time.Second}, true},
+		{"unknown_error", []string{`{"error":"invalid_client"}`}, "", []time.Duration{5 * time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			defer func(f func(time.Duration) <-chan time.Time) { after = f }(after)
+			after = func(d time.Duration) <-chan time.Time {
+				waits = append(waits, d)
+				c := make(chan time.Time, 1)
+				c <- time.Now()
+				return c
+			}
+
+			polls := 0
+			client := &test.MockHttpClient{
+				DoHandler: func(r *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(r.Body)
+					form, _ := url.ParseQuery(string(body))
+					if form.Get("device_code") != "d1" {
+						t.Errorf("PollDeviceToken() sent device code %q, want d1", form.Get("device_code"))
+					}
+
+					res := tt.responses[polls]
+					polls++
+					status := http.StatusOK
+					if res != token {
+						// Google responds with 428 while the user has yet to approve.
+						status = http.StatusPreconditionRequired
+					}
+					return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(res))}, nil
+				},
+			}
+
+			da := &DeviceAuthorization{DeviceCode: "d1", UserCode: "u1", Expires: time.Now().Add(time.Minute)}
+			got, err := PollDeviceToken(context.Background(), client, "https://idp.example.com/token", "device_code=d1&grant_type="+url.QueryEscape(GrantTypeDeviceCode), nil, da)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PollDeviceToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("PollDeviceToken() = %s, want %s", got, tt.want)
+			}
+
+			if len(waits) != len(tt.wantWaits) {
+				t.Fatalf("PollDeviceToken() waited %v, want %v", waits, tt.wantWaits)
+			}
+			for i, w := range tt.wantWaits {
+				if waits[i] != w {
+					t.Errorf("PollDeviceToken() waited %v, want %v", waits, tt.wantWaits)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package sso
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DiscoverDoc An OpenID Connect discovery document, normally found at
+// <issuer>/.well-known/openid-configuration.
+type DiscoverDoc struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	rawBytes                          []byte
+}
+
+func (dd *DiscoverDoc) Bytes() []byte {
+	return dd.rawBytes
+}
+
+// LoadDiscoverDoc Decode a discovery document.
+func LoadDiscoverDoc(data []byte) (*DiscoverDoc, error) {
+	dd := &DiscoverDoc{}
+
+	if e := json.Unmarshal(data, dd); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	dd.rawBytes = data
+
+	return dd, nil
+}
@@ -0,0 +1,77 @@
+package sso
+
+import (
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+// LoadDocument Load a document, such as a discovery document or JWKs, try
+// from cache first, then download from the internet if that fails or the
+// cached copy has expired.
+//
+//	The decode function is called with the data to verify it is usable; only
+//	data that decodes is saved to storage. When the download fails, an
+//	expired copy from the cache is used, so that logins keep working while
+//	the provider is unreachable.
+func LoadDocument(client HttpClient, store storage.Storage, filename, uri string, decode func([]byte) error) error {
+	stale := false
+
+	data, e1 := store.Load(filename)
+	if e1 != nil {
+		Log.Warnf("%v", e1.Error())
+	} else if e2 := decode(data); e2 != nil {
+		Log.Warnf("%v", e2.Error())
+	} else {
+		ci, e3 := loadCacheInfo(store, filename)
+		if e3 == nil && !ci.Expired(time.Now()) {
+			return nil
+		}
+		stale = true
+	}
+
+	Log.Infof(stdout.DocumentCacheMiss, filename)
+
+	e4 := RefreshDocument(client, store, filename, uri, decode)
+	if e4 != nil && stale {
+		Log.Warnf(stdout.DocumentStale, filename, e4.Error())
+		// Decode the cached copy again, as a failed decode of the download
+		// may have left it half set.
+		return decode(data)
+	}
+
+	return e4
+}
+
+// RefreshDocument Download a document, skipping the cache, then update the
+// cache with it.
+func RefreshDocument(client HttpClient, store storage.Storage, filename, uri string, decode func([]byte) error) error {
+	if uri == "" {
+		return &ErrNoURI{filename}
+	}
+
+	data, header, e1 := download(client, uri)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := decode(data); e != nil {
+		return e
+	}
+
+	if store == nil {
+		return nil
+	}
+
+	// Save to the storage device.
+	if e := store.Save(filename, data); e != nil {
+		Log.Warnf("%v", e.Error())
+		return nil
+	}
+
+	if e := saveCacheInfo(store, filename, NewCacheInfo(header, time.Now())); e != nil {
+		Log.Warnf("%v", e.Error())
+	}
+
+	return nil
+}
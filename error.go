@@ -0,0 +1,212 @@
+package sso
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrNoURI struct {
+	filename string
+}
+
+func (e *ErrNoURI) Error() string {
+	return fmt.Sprintf(stderr.NoURI, e.filename)
+}
+
+type ErrInvalidState struct {
+	msg      string
+	Location string
+	Code     int
+}
+
+func (e *ErrInvalidState) Error() string {
+	return e.msg
+}
+
+type ErrNoLoginInfo struct {
+	DeviceID string
+}
+
+func (e *ErrNoLoginInfo) Error() string {
+	return fmt.Sprintf(stderr.NoLoginInfo, e.DeviceID)
+}
+
+type ErrNoSession struct{}
+
+func (e *ErrNoSession) Error() string {
+	return "session manager is nil"
+}
+
+type ErrNoSessionData struct {
+	Key string
+}
+
+func (e *ErrNoSessionData) Error() string {
+	return fmt.Sprintf(stderr.NoSessionData, e.Key)
+}
+
+type ErrNonceMismatch struct {
+	Nonce string
+}
+
+func (e *ErrNonceMismatch) Error() string {
+	return fmt.Sprintf(stderr.NonceMismatch, e.Nonce)
+}
+
+type ErrStateExpired struct{}
+
+func (e *ErrStateExpired) Error() string {
+	return stderr.StateExpired
+}
+
+type ErrReturnURLNotAllowed struct {
+	URL string
+}
+
+func (e *ErrReturnURLNotAllowed) Error() string {
+	return fmt.Sprintf(stderr.ReturnURLNotAllowed, e.URL)
+}
+
+type ErrStateSignature struct{}
+
+func (e *ErrStateSignature) Error() string {
+	return stderr.StateSignature
+}
+
+type ErrAlgorithm struct {
+	Alg string
+}
+
+func (e *ErrAlgorithm) Error() string {
+	return fmt.Sprintf(stderr.Algorithm, e.Alg)
+}
+
+type ErrSignature struct {
+	msg string
+}
+
+func (e *ErrSignature) Error() string {
+	return fmt.Sprintf(stderr.Signature, e.msg)
+}
+
+type ErrUnknownKeyID struct {
+	Kid string
+}
+
+func (e *ErrUnknownKeyID) Error() string {
+	return fmt.Sprintf(stderr.UnknownKeyID, e.Kid)
+}
+
+type ErrDeviceAccessDenied struct{}
+
+func (e *ErrDeviceAccessDenied) Error() string {
+	return stderr.DeviceAccessDenied
+}
+
+type ErrDeviceCodeExpired struct{}
+
+func (e *ErrDeviceCodeExpired) Error() string {
+	return stderr.DeviceCodeExpired
+}
+
+type ErrCookieExpired struct {
+	Name string
+}
+
+func (e *ErrCookieExpired) Error() string {
+	return fmt.Sprintf(stderr.CookieExpired, e.Name)
+}
+
+type ErrCookieOpen struct {
+	Name string
+}
+
+func (e *ErrCookieOpen) Error() string {
+	return fmt.Sprintf(stderr.CookieOpen, e.Name)
+}
+
+type ErrNoCookie struct {
+	Name string
+}
+
+func (e *ErrNoCookie) Error() string {
+	return fmt.Sprintf(stderr.NoCookie, e.Name)
+}
+
+type ErrIdentityLinked struct {
+	Provider string
+	Subject  string
+}
+
+func (e *ErrIdentityLinked) Error() string {
+	return fmt.Sprintf(stderr.IdentityLinked, e.Provider, e.Subject)
+}
+
+type ErrNoAccount struct {
+	ID string
+}
+
+func (e *ErrNoAccount) Error() string {
+	return fmt.Sprintf(stderr.NoAccount, e.ID)
+}
+
+// ErrLinkRequired An identity signed in for the first time, with the verified
+// email of an existing account. The user must sign in to that account to
+// link the identity to it.
+type ErrLinkRequired struct {
+	AccountID string
+	Email     string
+	Provider  string
+	Subject   string
+}
+
+func (e *ErrLinkRequired) Error() string {
+	return fmt.Sprintf(stderr.LinkRequired, e.Provider, e.Subject, e.AccountID)
+}
+
+type ErrDeviceNotFound struct {
+	DeviceID string
+}
+
+func (e *ErrDeviceNotFound) Error() string {
+	return fmt.Sprintf(stderr.DeviceNotFound, e.DeviceID)
+}
+
+type ErrDeviceDenied struct {
+	ID      string
+	Reasons []string
+}
+
+func (e *ErrDeviceDenied) Error() string {
+	return fmt.Sprintf(stderr.DeviceDenied, e.ID, strings.Join(e.Reasons, ", "))
+}
+
+type ErrReauthenticate struct {
+	ID      string
+	Reasons []string
+}
+
+func (e *ErrReauthenticate) Error() string {
+	return fmt.Sprintf(stderr.Reauthenticate, e.ID, strings.Join(e.Reasons, ", "))
+}
+
+type ErrDeviceSignature struct {
+	Name string
+}
+
+func (e *ErrDeviceSignature) Error() string {
+	return fmt.Sprintf(stderr.DeviceSignature, e.Name)
+}
+
+type ErrVetoed struct {
+	Hook string
+	Err  error
+}
+
+func (e *ErrVetoed) Error() string {
+	return fmt.Sprintf(stderr.Vetoed, e.Hook, e.Err.Error())
+}
+
+func (e *ErrVetoed) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,74 @@
+package sso
+
+// HookEvent What a provider knows at a step of the login lifecycle.
+type HookEvent struct {
+	// Claims The claims of the ID token, nil for providers without one, such
+	// as GitHub.
+	Claims *IDTokenClaims
+	// Device The device of the client, when it is known.
+	Device *Device
+	// LoginInfo The login info of the client, when it is loaded.
+	LoginInfo *LoginInfo
+	Provider  string
+}
+
+// Hooks Called by a provider at each step of the login lifecycle, such as to
+// provision an account, notify the user, or count logins. Returning an error
+// vetoes the step, which the provider returns wrapped in an ErrVetoed. Embed
+// NopHooks to only implement some of them.
+type Hooks interface {
+	// OnLogin Called once the ID token is validated, before the token is
+	// kept by the provider.
+	OnLogin(event *HookEvent) error
+	// OnNewDevice Called when a device is added to the login info, before it
+	// is saved.
+	OnNewDevice(event *HookEvent) error
+	// OnRefresh Called once a refreshed token is validated, before the token
+	// is kept by the provider.
+	OnRefresh(event *HookEvent) error
+	// OnSignOut Called before the client is signed out. A sign-out cannot be
+	// vetoed, the error is returned after signing out.
+	OnSignOut(event *HookEvent) error
+}
+
+// NopHooks Hooks that allow every step.
+type NopHooks struct{}
+
+func (NopHooks) OnLogin(event *HookEvent) error     { return nil }
+func (NopHooks) OnNewDevice(event *HookEvent) error { return nil }
+func (NopHooks) OnRefresh(event *HookEvent) error   { return nil }
+func (NopHooks) OnSignOut(event *HookEvent) error   { return nil }
+
+// Hook names, for ErrVetoed.
+const (
+	HookLogin     = "OnLogin"
+	HookNewDevice = "OnNewDevice"
+	HookRefresh   = "OnRefresh"
+	HookSignOut   = "OnSignOut"
+)
+
+// RunHook Call the hook, returning its error wrapped in an ErrVetoed, so
+// providers need not check for nil hooks.
+func RunHook(hooks Hooks, hook string, event *HookEvent) error {
+	if hooks == nil {
+		return nil
+	}
+
+	var err error
+	switch hook {
+	case HookLogin:
+		err = hooks.OnLogin(event)
+	case HookNewDevice:
+		err = hooks.OnNewDevice(event)
+	case HookRefresh:
+		err = hooks.OnRefresh(event)
+	case HookSignOut:
+		err = hooks.OnSignOut(event)
+	}
+
+	if err != nil {
+		return &ErrVetoed{hook, err}
+	}
+
+	return nil
+}
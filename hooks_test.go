@@ -0,0 +1,58 @@
+package sso
+
+import (
+	"errors"
+	"testing"
+)
+
+var errNoRefresh = errors.New("refresh is turned off")
+
+type refreshHooks struct {
+	NopHooks
+	provider string
+}
+
+func (h *refreshHooks) OnRefresh(event *HookEvent) error {
+	h.provider = event.Provider
+	return errNoRefresh
+}
+
+func TestRunHook(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   Hooks
+		hook    string
+		wantErr bool
+	}{
+		{"nil_hooks", nil, HookRefresh, false},
+		{"nop", NopHooks{}, HookRefresh, false},
+		{"allowed", &refreshHooks{}, HookLogin, false},
+		{"vetoed", &refreshHooks{}, HookRefresh, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RunHook(tt.hooks, tt.hook, &HookEvent{Provider: "google"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunHook() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				return
+			}
+
+			var vetoed *ErrVetoed
+			if !errors.As(err, &vetoed) || vetoed.Hook != tt.hook {
+				t.Errorf("RunHook() error = %v, want an ErrVetoed for %v", err, tt.hook)
+			}
+
+			if !errors.Is(err, errNoRefresh) {
+				t.Errorf("RunHook() error = %v, does not wrap the hook error", err)
+			}
+
+			if got := tt.hooks.(*refreshHooks).provider; got != "google" {
+				t.Errorf("RunHook() passed provider %q, want google", got)
+			}
+		})
+	}
+}
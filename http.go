@@ -0,0 +1,117 @@
+package sso
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HttpClient Methods needed to make HTTP request.
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Download Retrieve a document from the internet, for example a discovery
+// document or the JWKs of an OIDC provider.
+func Download(client HttpClient, uri string) ([]byte, error) {
+	resBody, _, e1 := download(client, uri)
+
+	return resBody, e1
+}
+
+// SendWithRetry Make an HTTP request, retrying up to so many times.
+// NOTE: Response will be nil when the expected status code is not met, be
+// careful to set the correct code, this function does not work if multiple HTTP
+// status codes are acceptable.
+//
+// Also, you can have an error returned with a valid response. This is due to
+// the fact that any errors caused by previous attempts are compiled into a
+// single error and returned along with the valid response.
+func SendWithRetry(
+	httpClient HttpClient,
+	method, url string,
+	data []byte,
+	headers http.Header,
+	code,
+	retries int,
+) (*http.Response, error) {
+	body := bytes.NewBuffer(data)
+
+	req, e1 := http.NewRequest(method, url, body)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.BuildRequest, e1.Error())
+	}
+
+	req.Header = headers
+	var lastResponse *http.Response
+	var errMessage string
+	ep := endpoint(req)
+
+	for attempt := 1; attempt <= retries; attempt++ {
+		// Reset the body, since the previous attempt has read it.
+		if attempt > 1 {
+			req.Body = io.NopCloser(bytes.NewReader(data))
+			count(MetricHTTPRetries, Labels{"endpoint": ep})
+		}
+
+		start := time.Now()
+		res, err := httpClient.Do(req)
+		if err != nil {
+			observe(MetricHTTPDuration, Labels{"endpoint": ep, "status": "error"}, time.Since(start).Seconds())
+			errMessage += fmt.Sprintf(stderr.RetryRequest, err.Error())
+			continue
+		}
+
+		observe(MetricHTTPDuration, Labels{"endpoint": ep, "status": strconv.Itoa(res.StatusCode)}, time.Since(start).Seconds())
+
+		if res.StatusCode == code {
+			lastResponse = res
+			break
+		}
+
+		// condition where response is not the expected status code but err is
+		// nil
+		// We throw this back at the app to let the dev know they should handle
+		// this particular case.
+		resBody, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+
+		errMessage += fmt.Sprintf(stderr.UnexpectedCode, attempt, url, res.StatusCode, string(resBody))
+
+		res = nil
+	}
+
+	var lastErr error
+	if errMessage != "" {
+		lastErr = fmt.Errorf("%v", errMessage)
+	}
+
+	if lastResponse == nil {
+		count(MetricHTTPFailures, Labels{"endpoint": ep})
+		return nil, lastErr
+	}
+
+	return lastResponse, lastErr
+}
+
+// download Retrieve a document from the internet along with the headers of
+// the response.
+func download(client HttpClient, uri string) ([]byte, http.Header, error) {
+	Log.Infof(stdout.Url, uri)
+
+	res, e1 := SendWithRetry(client, "GET", uri, nil, nil, http.StatusOK, 3)
+	if e1 != nil && res == nil {
+		return nil, nil, fmt.Errorf(stderr.Response, e1.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	resBody, e2 := io.ReadAll(res.Body)
+	if e2 != nil {
+		return nil, nil, fmt.Errorf(stderr.ReadResponse, e2.Error())
+	}
+
+	return resBody, res.Header, nil
+}
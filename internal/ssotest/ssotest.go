@@ -0,0 +1,69 @@
+// Package ssotest Fixtures shared by the tests of the providers.
+package ssotest
+
+import (
+	"bytes"
+	_ "embed"
+	"io"
+	"net/http"
+	"strings"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/stdlib/test"
+)
+
+// Certificate The JWKs with the public half of Key, under the key ID
+// test-kid-1.
+//
+//go:embed testdata/certificate.json
+var Certificate []byte
+
+// Key The RSA private key the ID tokens are signed with.
+//
+//go:embed testdata/test-rsa.key
+var Key []byte
+
+// Session An in memory session.
+type Session map[string][]byte
+
+func (s Session) Get(key string) []byte        { return s[key] }
+func (s Session) Remove(key string) error      { delete(s, key); return nil }
+func (s Session) Set(key string, value []byte) { s[key] = value }
+
+// Client Respond with the body of the longest path suffix the URL path ends
+// with, 404 when there is none. A DELETE is answered with 204, as revocation
+// endpoints do. Each request is appended to requests when it is not nil.
+func Client(bodies map[string]string, requests *[]*http.Request) *test.MockHttpClient {
+	return &test.MockHttpClient{
+		DoHandler: func(r *http.Request) (*http.Response, error) {
+			if requests != nil {
+				*requests = append(*requests, r)
+			}
+
+			suffix := ""
+			for path := range bodies {
+				if strings.HasSuffix(r.URL.Path, path) && len(path) > len(suffix) {
+					suffix = path
+				}
+			}
+
+			body, ok := bodies[suffix]
+			if !ok {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			}
+
+			code := 200
+			if r.Method == "DELETE" {
+				code = 204
+			}
+
+			return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+		},
+	}
+}
+
+// IDToken Sign an ID token with Key.
+func IDToken(payload jwt.ClaimSet) string {
+	idToken, _ := jwt.Token(jwt.ClaimSet{"alg": "RS256", "kid": "test-kid-1"}, payload, Key)
+	return idToken
+}
@@ -0,0 +1,105 @@
+package sso
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JWK A JSON Web Key as published at the jwks_uri of an OIDC provider.
+type JWK struct {
+	Kty string `json:"kty"`
+	E   string `json:"e"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+
+	publicKey *rsa.PublicKey
+}
+
+const algRS256 = "RS256"
+
+// JwksUriv3 A set of JSON Web Keys used to validate ID tokens.
+type JwksUriv3 struct {
+	Keys     []*JWK `json:"keys"`
+	index    map[string]*JWK
+	rawBytes []byte
+}
+
+func (k *JwksUriv3) Bytes() []byte {
+	return k.rawBytes
+}
+
+func (k *JwksUriv3) String() string {
+	return string(k.rawBytes)
+}
+
+func LoadJwksUriv3(data []byte) (*JwksUriv3, error) {
+	cert := &JwksUriv3{}
+
+	if e := json.Unmarshal(data, cert); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	cert.rawBytes = data
+
+	// Index the RSA keys by key ID, so they are only parsed once. Keys of
+	// other types are skipped, as only RS256 is supported.
+	cert.index = make(map[string]*JWK, len(cert.Keys))
+	for _, jwk := range cert.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+
+		pk, e := parseRSAPublicKey(jwk)
+		if e != nil {
+			Log.Warnf(stderr.ParseKey, jwk.Kid, e.Error())
+			continue
+		}
+
+		jwk.publicKey = pk
+		cert.index[jwk.Kid] = jwk
+	}
+
+	return cert, nil
+}
+
+// ParseRSAPublicKeys Convert JWK structures into keys. This was meant to handle
+// certs in the format that Google jwks_uri v3 returns.
+func ParseRSAPublicKeys(certs []*JWK) ([]*rsa.PublicKey, error) {
+	keys := make([]*rsa.PublicKey, len(certs))
+	for i, key := range certs {
+		pk, e := parseRSAPublicKey(key)
+		if e != nil {
+			return nil, e
+		}
+
+		keys[i] = pk
+	}
+
+	return keys, nil
+}
+
+// parseRSAPublicKey Convert a JWK into an RSA public key.
+func parseRSAPublicKey(key *JWK) (*rsa.PublicKey, error) {
+	n := make([]byte, base64.RawURLEncoding.DecodedLen(len(key.N)))
+	e := make([]byte, base64.RawURLEncoding.DecodedLen(len(key.E)))
+
+	_, e1 := base64.RawURLEncoding.Decode(n, []byte(key.N))
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.DecodeBase64URL, e1.Error())
+	}
+
+	_, e2 := base64.RawURLEncoding.Decode(e, []byte(key.E))
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.DecodeBase64URL, e2.Error())
+	}
+
+	eVar := int(new(big.Int).SetBytes(e).Int64())
+	nVar := new(big.Int).SetBytes(n)
+
+	return &rsa.PublicKey{E: eVar, N: nVar}, nil
+}
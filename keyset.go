@@ -0,0 +1,97 @@
+package sso
+
+import (
+	"crypto/rsa"
+	"sync"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+)
+
+// KeyRefreshInterval The least amount of time between downloads of a JWKS
+// caused by a token signed with an unknown key ID.
+var KeyRefreshInterval = 5 * time.Minute
+
+// keyRefreshes When the JWKS at each URI was last downloaded because of an
+// unknown key ID. Kept for the package, as a provider is made for each
+// request.
+var keyRefreshes = struct {
+	sync.Mutex
+	last map[string]time.Time
+}{last: map[string]time.Time{}}
+
+// Key Find the RSA public key with the key ID, that may be used with the
+// algorithm, both taken from the header of a JWT.
+//
+//	When the key ID is empty, the only key in the set is used, as some
+//	providers leave it out when they publish a single key.
+func (k *JwksUriv3) Key(kid, alg string) (*rsa.PublicKey, error) {
+	if alg != algRS256 {
+		return nil, &ErrAlgorithm{alg}
+	}
+
+	if k == nil {
+		return nil, &ErrUnknownKeyID{kid}
+	}
+
+	if kid == "" && len(k.Keys) == 1 {
+		kid = k.Keys[0].Kid
+	}
+
+	jwk, ok := k.index[kid]
+	if !ok {
+		return nil, &ErrUnknownKeyID{kid}
+	}
+
+	if jwk.Alg != "" && jwk.Alg != alg {
+		return nil, &ErrAlgorithm{alg}
+	}
+
+	return jwk.publicKey, nil
+}
+
+// VerifySignature Verify the signature of a JWT using the key named by the
+// key ID in its header. When the key ID is unknown, the provider may have
+// rotated its keys, so refresh is called to download the JWKS from the URI
+// again, but no more than once per KeyRefreshInterval.
+func VerifySignature(idToken string, header jwt.ClaimSet, jwks *JwksUriv3, uri string, refresh func() (*JwksUriv3, error)) error {
+	kid, _ := header["kid"].(string)
+	alg, _ := header["alg"].(string)
+
+	key, e1 := jwks.Key(kid, alg)
+	if _, ok := e1.(*ErrUnknownKeyID); ok && allowKeyRefresh(uri) {
+		Log.Infof(stdout.KeyRefresh, kid, uri)
+
+		fresh, e2 := refresh()
+		if e2 != nil {
+			return e2
+		}
+
+		key, e1 = fresh.Key(kid, alg)
+	}
+
+	if e1 != nil {
+		return e1
+	}
+
+	if e := jwt.ValidateSignatureRS256Pub([]byte(idToken), key); e != nil {
+		return &ErrSignature{e.Error()}
+	}
+
+	return nil
+}
+
+// allowKeyRefresh Check and record a download of the JWKS at the URI.
+func allowKeyRefresh(uri string) bool {
+	keyRefreshes.Lock()
+	defer keyRefreshes.Unlock()
+
+	now := time.Now()
+	if last, ok := keyRefreshes.last[uri]; ok && now.Sub(last) < KeyRefreshInterval {
+		return false
+	}
+
+	keyRefreshes.last[uri] = now
+
+	return true
+}
@@ -0,0 +1,121 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+)
+
+// fixtureKey Generate an RSA key, returned as PEM along with its JWK.
+func fixtureKey(t *testing.T, kid string) ([]byte, *JWK) {
+	key, e1 := rsa.GenerateKey(rand.Reader, 2048)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return keyPEM, &JWK{
+		Kty: "RSA",
+		Kid: kid,
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// fixtureJwks Make a JWKS the way it would be loaded from a provider.
+func fixtureJwks(t *testing.T, keys ...*JWK) *JwksUriv3 {
+	data, _ := json.Marshal(&JwksUriv3{Keys: keys})
+	jwks, e1 := LoadJwksUriv3(data)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	return jwks
+}
+
+func TestVerifySignature(t *testing.T) {
+	oldPEM, oldJWK := fixtureKey(t, "kid-1")
+	newPEM, newJWK := fixtureKey(t, "kid-2")
+	ecJWK := &JWK{Kty: "EC", Kid: "kid-ec", Alg: "ES256"}
+
+	tests := []struct {
+		name        string
+		kid         string
+		alg         string
+		keyPEM      []byte
+		jwks        *JwksUriv3
+		wantRefresh int
+		wantErr     bool
+	}{
+		{"known_kid", "kid-1", "RS256", oldPEM, fixtureJwks(t, oldJWK, ecJWK), 0, false},
+		{"no_kid_single_key", "", "RS256", oldPEM, fixtureJwks(t, oldJWK), 0, false},
+		{"rotated_key", "kid-2", "RS256", newPEM, fixtureJwks(t, oldJWK), 1, false},
+		{"no_keys", "kid-2", "RS256", newPEM, nil, 1, false},
+		{"wrong_key", "kid-1", "RS256", newPEM, fixtureJwks(t, oldJWK), 0, true},
+		{"alg_not_allowed", "kid-1", "HS256", oldPEM, fixtureJwks(t, oldJWK), 0, true},
+		{"unknown_kid", "kid-3", "RS256", newPEM, fixtureJwks(t, oldJWK), 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, _ := jwt.Token(jwt.ClaimSet{"alg": "RS256", "kid": tt.kid}, jwt.ClaimSet{"sub": "s1"}, tt.keyPEM)
+			header := jwt.ClaimSet{"alg": tt.alg, "kid": tt.kid}
+			uri := "https://idp.example.com/" + tt.name
+
+			refreshes := 0
+			refresh := func() (*JwksUriv3, error) {
+				refreshes++
+				return fixtureJwks(t, oldJWK, newJWK), nil
+			}
+
+			err := VerifySignature(idToken, header, tt.jwks, uri, refresh)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySignature() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if refreshes != tt.wantRefresh {
+				t.Errorf("VerifySignature() refreshed %v times, want %v", refreshes, tt.wantRefresh)
+			}
+		})
+	}
+}
+
+func TestVerifySignature_RateLimit(t *testing.T) {
+	keyPEM, jwk := fixtureKey(t, "kid-1")
+	idToken, _ := jwt.Token(jwt.ClaimSet{"alg": "RS256", "kid": "kid-9"}, jwt.ClaimSet{"sub": "s1"}, keyPEM)
+	header := jwt.ClaimSet{"alg": "RS256", "kid": "kid-9"}
+	uri := "https://idp.example.com/rate-limit"
+
+	refreshes := 0
+	refresh := func() (*JwksUriv3, error) {
+		refreshes++
+		return fixtureJwks(t, jwk), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = VerifySignature(idToken, header, fixtureJwks(t, jwk), uri, refresh)
+	}
+
+	if refreshes != 1 {
+		t.Errorf("VerifySignature() refreshed %v times, want 1", refreshes)
+	}
+
+	defer func(d time.Duration) { KeyRefreshInterval = d }(KeyRefreshInterval)
+	KeyRefreshInterval = 0
+
+	_ = VerifySignature(idToken, header, fixtureJwks(t, jwk), uri, refresh)
+	if refreshes != 2 {
+		t.Errorf("VerifySignature() refreshed %v times after the interval, want 2", refreshes)
+	}
+}
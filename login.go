@@ -1,39 +1,129 @@
 package sso
 
-import (
-	"fmt"
-	"github.com/mileusna/useragent"
-)
+import "sort"
 
 type LoginInfo struct {
-	AccountID    string
-	Devices      map[string]*Device `json:"devices"`
-	Email        string
+	AccountID string
+	Devices   map[string]*Device `json:"devices"`
+	Email     string
+	// FamilyName and GivenName The name of the user, for providers that only
+	// send it once, such as Apple on first consent.
+	FamilyName   string      `json:"family_name,omitempty"`
+	GivenName    string      `json:"given_name,omitempty"`
 	ClientID     string      `json:"google_id"`
 	RefreshToken interface{} `json:"refresh_token"`
 	Token        Token       `json:"token"`
 }
 
-// LookupDevice Search for the device in the login information.
-func (li *LoginInfo) LookupDevice(deviceID, sessionID, userAgent string) (*Device, error) {
-	points := 0
-	// Lookup the device, otherwise treat it as a new device.
+// ListDevices Return the devices, with the most recently active first, for
+// showing the user where they are signed in.
+func (li *LoginInfo) ListDevices() []*Device {
+	devices := make([]*Device, 0, len(li.Devices))
+	for _, d := range li.Devices {
+		devices = append(devices, d)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].LastActivity.Equal(devices[j].LastActivity) {
+			return devices[i].ID < devices[j].ID
+		}
+		return devices[i].LastActivity.After(devices[j].LastActivity)
+	})
+
+	return devices
+}
+
+// AssessDevice Search for the device in the login information, then have
+// Risk decide whether it can continue. The signals need not have the device
+// set, it is filled in when found.
+func (li *LoginInfo) AssessDevice(deviceID string, signals *RiskSignals) (*Device, *RiskAssessment, error) {
 	device, found := li.Devices[deviceID]
 	if !found {
-		return nil, fmt.Errorf("%v", "Device not found")
+		return nil, nil, &ErrDeviceNotFound{deviceID}
 	}
 
-	// Compare User Agent to validate it is the same device.
-	dua := device.UserAgent
-	ua := useragent.Parse(userAgent)
-	if dua.Device != ua.Device || dua.OSVersion != ua.OSVersion || dua.Name != ua.Name {
-		// this is a new device
-		return nil, fmt.Errorf("device has bad signature, tampering suspected")
+	signals.Device = device
+
+	return device, Risk.Evaluate(signals), nil
+}
+
+// LookupDevice Search for the device in the login information. A device the
+// Risk evaluator denies is not returned, and one it wants to sign in again
+// is returned with an ErrReauthenticate.
+func (li *LoginInfo) LookupDevice(deviceID, sessionID, userAgent string) (*Device, error) {
+	return li.LookupDeviceWithIP(deviceID, sessionID, userAgent, "")
+}
+
+// LookupDeviceWithIP Same as LookupDevice, with the IP address of the request
+// for Risk to compare with the one saved on the device.
+func (li *LoginInfo) LookupDeviceWithIP(deviceID, sessionID, userAgent, ipAddress string) (*Device, error) {
+	device, ra, e1 := li.AssessDevice(deviceID, &RiskSignals{
+		IPAddress: ipAddress,
+		SessionID: sessionID,
+		UserAgent: userAgent,
+	})
+	if e1 != nil {
+		return nil, e1
 	}
 
-	// TODO: what to do if session does not match.
-	if sessionID == device.SessionID {
-		points++
+	switch ra.Decision {
+	case RiskDeny:
+		return nil, &ErrDeviceDenied{deviceID, ra.Reasons}
+	case RiskReauthenticate:
+		return device, &ErrReauthenticate{deviceID, ra.Reasons}
 	}
+
 	return device, nil
 }
+
+// RenameDevice Give the device a label the user will recognize.
+func (li *LoginInfo) RenameDevice(deviceID, label string) error {
+	device, found := li.Devices[deviceID]
+	if !found {
+		return &ErrDeviceNotFound{deviceID}
+	}
+
+	device.Label = label
+
+	return nil
+}
+
+// RevokeDevice Sign the device out and stop trusting it, so that it must
+// authenticate again. The device stays listed, to show where the user has
+// signed in.
+func (li *LoginInfo) RevokeDevice(deviceID string) error {
+	device, found := li.Devices[deviceID]
+	if !found {
+		return &ErrDeviceNotFound{deviceID}
+	}
+
+	device.SessionID = ""
+	device.Trusted = false
+
+	return nil
+}
+
+// TrustDevice Mark the device as one the user owns, or not.
+func (li *LoginInfo) TrustDevice(deviceID string, trusted bool) error {
+	device, found := li.Devices[deviceID]
+	if !found {
+		return &ErrDeviceNotFound{deviceID}
+	}
+
+	device.Trusted = trusted
+
+	return nil
+}
+
+// UnbindDevice Drop the session bound to the device, so that it has to sign
+// in again.
+func (li *LoginInfo) UnbindDevice(deviceID string) bool {
+	device, found := li.Devices[deviceID]
+	if !found {
+		return false
+	}
+
+	device.SessionID = ""
+
+	return true
+}
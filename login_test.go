@@ -0,0 +1,90 @@
+package sso
+
+import (
+	"testing"
+	"time"
+)
+
+func fixtureLoginInfo() *LoginInfo {
+	now := time.Now()
+	li := &LoginInfo{Devices: map[string]*Device{}}
+	for i, id := range []string{"d1", "d2", "d3"} {
+		d := NewDeviceWithID(id, "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0", "s-"+id, "google")
+		d.LastActivity = now.Add(time.Duration(i) * time.Hour)
+		li.Devices[id] = d
+	}
+
+	return li
+}
+
+func TestLoginInfo_ListDevices(t *testing.T) {
+	got := fixtureLoginInfo().ListDevices()
+
+	want := []string{"d3", "d2", "d1"}
+	for i, d := range got {
+		if d.ID != want[i] {
+			t.Errorf("ListDevices()[%v] = %v, want %v", i, d.ID, want[i])
+		}
+	}
+}
+
+func TestLoginInfo_ManageDevice(t *testing.T) {
+	tests := []struct {
+		name     string
+		deviceID string
+		manage   func(li *LoginInfo, deviceID string) error
+		check    func(d *Device) bool
+		wantErr  bool
+	}{
+		{
+			"rename",
+			"d1",
+			func(li *LoginInfo, deviceID string) error { return li.RenameDevice(deviceID, "Work laptop") },
+			func(d *Device) bool { return d.Label == "Work laptop" },
+			false,
+		},
+		{
+			"trust",
+			"d2",
+			func(li *LoginInfo, deviceID string) error { return li.TrustDevice(deviceID, true) },
+			func(d *Device) bool { return d.Trusted },
+			false,
+		},
+		{
+			"revoke",
+			"d3",
+			func(li *LoginInfo, deviceID string) error {
+				_ = li.TrustDevice(deviceID, true)
+				return li.RevokeDevice(deviceID)
+			},
+			func(d *Device) bool { return !d.Trusted && !d.Authenticated("s-d3") },
+			false,
+		},
+		{
+			"not_found",
+			"d9",
+			func(li *LoginInfo, deviceID string) error { return li.RevokeDevice(deviceID) },
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			li := fixtureLoginInfo()
+
+			err := tt.manage(li, tt.deviceID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%v error = %v, wantErr %v", tt.name, err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !tt.check(li.Devices[tt.deviceID]) {
+				t.Errorf("%v device = %+v", tt.name, li.Devices[tt.deviceID])
+			}
+		})
+	}
+}
@@ -1,12 +1,125 @@
 package sso
 
 var stderr = struct {
+	Algorithm,
+	AuditNoFrom,
+	AuditRecord,
+	BuildRequest,
+	CipherKey,
+	CookieExpired,
+	CookieOpen,
+	DecodeBase64URL,
 	DecodeJSON,
-	EncodeJSON string
+	Decrypt,
+	DeviceAccessDenied,
+	DeviceCodeExpired,
+	DeviceDenied,
+	DeviceNotFound,
+	DeviceSignature,
+	DeviceToken,
+	EncodeJSON,
+	IdentityLinked,
+	InvalidState,
+	LinkRequired,
+	NoAccount,
+	NoCookie,
+	NoCookieCipher,
+	NoDeviceAuthURI,
+	NoDeviceKey,
+	NoLoginInfo,
+	NonceMismatch,
+	NoRevocationURI,
+	NoSessionData,
+	NoStateKey,
+	NoTokenURI,
+	NoURI,
+	ParseKey,
+	PKCENotSupported,
+	Random,
+	ReadResponse,
+	Reauthenticate,
+	Response,
+	RetryRequest,
+	ReturnURLNotAllowed,
+	Signature,
+	SignOut,
+	StateExpired,
+	StateMismatch,
+	StateSignature,
+	UnexpectedCode,
+	UnknownKeyID,
+	UnlinkLastIdentity,
+	UserInfoSubject,
+	Vetoed string
 }{
-	DecodeJSON: "could not decode JSON: %v",
-	EncodeJSON: "unable encode JSON: %v",
+	Algorithm:           "algorithm %q is not allowed for the key",
+	AuditNoFrom:         "an audit query needs a From time",
+	AuditRecord:         "could not record the audit event: %v",
+	BuildRequest:        "cannot build the request: %v",
+	CipherKey:           "invalid cipher key: %v",
+	CookieExpired:       "cookie %v has expired",
+	CookieOpen:          "cookie %v could not be decrypted with any of the keys",
+	DecodeBase64URL:     "failed to decode base64URL: %v",
+	DecodeJSON:          "could not decode JSON: %v",
+	Decrypt:             "could not decrypt: %v",
+	DeviceAccessDenied:  "the user denied the device access",
+	DeviceCodeExpired:   "the device code expired before the user approved the device",
+	DeviceDenied:        "device %v was denied, tampering suspected: %v",
+	DeviceNotFound:      "device %v was not found",
+	DeviceSignature:     "the signature of cookie %v is not valid",
+	DeviceToken:         "unexpected response from the token endpoint while polling, HTTP status code %v with body %v",
+	EncodeJSON:          "unable encode JSON: %v",
+	IdentityLinked:      "identity %v/%v is already linked to an account",
+	InvalidState:        "invalid unique session token state values",
+	LinkRequired:        "identity %v/%v has the verified email of account %v, sign in to that account to link it",
+	NoAccount:           "account %v was not found",
+	NoCookie:            "cookie %v was not found",
+	NoCookieCipher:      "a cipher is required to seal the cookie",
+	NoDeviceAuthURI:     "the provider has no device authorization endpoint",
+	NoDeviceKey:         "a key is required to sign the device cookie",
+	NoLoginInfo:         "login info %v was not found",
+	NonceMismatch:       "nonce claim %q does not match the nonce sent with the login",
+	NoRevocationURI:     "the provider has no revocation endpoint",
+	NoSessionData:       "no session data for %v",
+	NoStateKey:          "a key is required to sign the state",
+	NoTokenURI:          "the provider has no token endpoint",
+	NoURI:               "no URL to download %v from",
+	ParseKey:            "skipping key %v: %v",
+	PKCENotSupported:    "PKCE is required, but the provider does not advertise the S256 code challenge method",
+	Random:              "could not generate random bytes: %v",
+	ReadResponse:        "could not read response: %v",
+	Reauthenticate:      "device %v must sign in again: %v",
+	Response:            "not the expected response: %v",
+	RetryRequest:        "request with retry %v",
+	ReturnURLNotAllowed: "return URL %q is not allowed",
+	Signature:           "could not verify the signature of the token: %v",
+	SignOut:             "signing out failed: %v",
+	StateExpired:        "the login took too long, its state has expired",
+	StateMismatch:       "unique session token state mismatch",
+	StateSignature:      "the state signature is invalid",
+	UnexpectedCode:      "attempt %v to url %v has returned HTTP status code %v with body %v",
+	UnknownKeyID:        "no key with ID %q to verify the token",
+	UnlinkLastIdentity:  "cannot unlink %v, it is the last identity of account %v",
+	UserInfoSubject:     "userinfo sub %q does not match the ID token sub %q",
+	Vetoed:              "vetoed by the %v hook: %v",
 }
 
 var stdout = struct {
-}{}
+	DevicePending,
+	DevicesEvicted,
+	DocumentCacheMiss,
+	DocumentStale,
+	KeyRefresh,
+	Plaintext,
+	Url,
+	UserInfoFallback string
+}{
+	DevicePending:     "waiting for the user to enter the code %v",
+	DevicesEvicted:    "dropped %v devices from the login info of account %v",
+	DocumentCacheMiss: "unable to load %v from cache, downloading",
+	DocumentStale:     "using the expired copy of %v from cache, as it could not be downloaded: %v",
+	KeyRefresh:        "unknown key ID %q, downloading the keys from %v again",
+	Plaintext:         "%v is not encrypted, it will be encrypted when saved",
+	Url:               "requesting URL: %v",
+	UserInfoFallback:  "using the ID token claims, as the userinfo request failed: %v",
+}
@@ -0,0 +1,96 @@
+package sso
+
+import (
+	"net/http"
+	"time"
+)
+
+// Labels The dimensions of a metric, such as the provider or endpoint.
+type Labels map[string]string
+
+// MetricsRecorder Record counters and histograms, Prometheus records them
+// in memory and exports them in the Prometheus text format.
+type MetricsRecorder interface {
+	// Add Increase a counter by the value.
+	Add(name string, labels Labels, value float64)
+	// Observe Record a value in a histogram, such as a latency in seconds.
+	Observe(name string, labels Labels, value float64)
+}
+
+// Metrics Where the library records its metrics, nil records nothing.
+var Metrics MetricsRecorder
+
+// Names of the metrics recorded by the library.
+const (
+	// MetricHTTPDuration Seconds each HTTP attempt made by SendWithRetry
+	// took, by endpoint and status, the count is the number of attempts.
+	MetricHTTPDuration = "sso_http_request_duration_seconds"
+	// MetricHTTPFailures Requests made by SendWithRetry that ran out of
+	// attempts, by endpoint.
+	MetricHTTPFailures = "sso_http_failures_total"
+	// MetricHTTPRetries Attempts made by SendWithRetry after the first, by
+	// endpoint.
+	MetricHTTPRetries = "sso_http_retries_total"
+	// MetricTokenDuration Seconds a request to the token endpoint took, by
+	// provider, grant, and result.
+	MetricTokenDuration = "sso_token_request_duration_seconds"
+	// MetricTokenValidationFailures ID tokens that failed validation, by
+	// provider and reason.
+	MetricTokenValidationFailures = "sso_token_validation_failures_total"
+	// MetricTokenValidations ID tokens validated, by provider.
+	MetricTokenValidations = "sso_token_validations_total"
+)
+
+// Reasons an ID token fails validation, for MetricTokenValidationFailures.
+const (
+	TokenReasonAudience        = "audience"
+	TokenReasonAuthorizedParty = "azp"
+	TokenReasonExpired         = "expired"
+	TokenReasonHostedDomain    = "hd"
+	TokenReasonIssuer          = "issuer"
+	TokenReasonMalformed       = "malformed"
+	TokenReasonNoKeys          = "no_keys"
+	TokenReasonSignature       = "signature"
+)
+
+// CountValidation Count an ID token validated by the provider, and why it
+// failed, an empty reason when it is valid.
+func CountValidation(provider, reason string) {
+	count(MetricTokenValidations, Labels{"provider": provider})
+
+	if reason != "" {
+		count(MetricTokenValidationFailures, Labels{"provider": provider, "reason": reason})
+	}
+}
+
+// ObserveTokenRequest Record how long a request to the token endpoint of the
+// provider took, since start, and whether it failed, which is when there is
+// no response.
+func ObserveTokenRequest(provider, grant string, start time.Time, res *http.Response) {
+	result := "ok"
+	if res == nil {
+		result = "error"
+	}
+
+	observe(MetricTokenDuration, Labels{"grant": grant, "provider": provider, "result": result}, time.Since(start).Seconds())
+}
+
+// count Increase a counter by one.
+func count(name string, labels Labels) {
+	if Metrics != nil {
+		Metrics.Add(name, labels, 1)
+	}
+}
+
+// endpoint The endpoint label of a request, the URL without the query, so
+// that the label does not grow with every code or token sent.
+func endpoint(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+}
+
+// observe Record a value in a histogram.
+func observe(name string, labels Labels, value float64) {
+	if Metrics != nil {
+		Metrics.Observe(name, labels, value)
+	}
+}
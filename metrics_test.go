@@ -0,0 +1,98 @@
+package sso
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kohirens/stdlib/test"
+)
+
+func TestSendWithRetry_Metrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     []string
+	}{
+		{
+			"first_attempt",
+			[]int{200},
+			[]string{
+				`sso_http_request_duration_seconds_count{endpoint="https://idp.example.com/token",status="200"} 1`,
+			},
+		},
+		{
+			"retried",
+			[]int{503, 0, 200},
+			[]string{
+				`sso_http_request_duration_seconds_count{endpoint="https://idp.example.com/token",status="503"} 1`,
+				`sso_http_request_duration_seconds_count{endpoint="https://idp.example.com/token",status="error"} 1`,
+				`sso_http_request_duration_seconds_count{endpoint="https://idp.example.com/token",status="200"} 1`,
+				`sso_http_retries_total{endpoint="https://idp.example.com/token"} 2`,
+			},
+		},
+		{
+			"out_of_attempts",
+			[]int{500, 500, 500},
+			[]string{
+				`sso_http_request_duration_seconds_count{endpoint="https://idp.example.com/token",status="500"} 3`,
+				`sso_http_retries_total{endpoint="https://idp.example.com/token"} 2`,
+				`sso_http_failures_total{endpoint="https://idp.example.com/token"} 1`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrometheus()
+			Metrics = p
+			defer func() { Metrics = nil }()
+
+			attempt := 0
+			client := &test.MockHttpClient{
+				DoHandler: func(r *http.Request) (*http.Response, error) {
+					status := tt.statuses[attempt]
+					attempt++
+					if status == 0 {
+						return nil, fmt.Errorf("connection reset")
+					}
+					return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+				},
+			}
+
+			_, _ = SendWithRetry(client, "POST", "https://idp.example.com/token?code=c1", nil, http.Header{}, http.StatusOK, 3)
+
+			buf := &bytes.Buffer{}
+			_, _ = p.WriteTo(buf)
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want+"\n") {
+					t.Errorf("SendWithRetry() recorded %v, want %v", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountValidation(t *testing.T) {
+	p := NewPrometheus()
+	Metrics = p
+	defer func() { Metrics = nil }()
+
+	CountValidation("google", "")
+	CountValidation("google", TokenReasonSignature)
+
+	buf := &bytes.Buffer{}
+	_, _ = p.WriteTo(buf)
+	want := `# HELP sso_token_validation_failures_total ID tokens that failed validation.
+# TYPE sso_token_validation_failures_total counter
+sso_token_validation_failures_total{provider="google",reason="signature"} 1
+# HELP sso_token_validations_total ID tokens validated.
+# TYPE sso_token_validations_total counter
+sso_token_validations_total{provider="google"} 2
+`
+	if got := buf.String(); got != want {
+		t.Errorf("CountValidation() recorded %v, want %v", got, want)
+	}
+}
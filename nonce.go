@@ -0,0 +1,46 @@
+package sso
+
+import "fmt"
+
+// SaveNonce Generate a nonce for a login and keep it in the session under
+// the key, so that the ID token returned for the login can be matched to it.
+func SaveNonce(session SessionManager, key string) (string, error) {
+	if session == nil {
+		return "", &ErrNoSession{}
+	}
+
+	nonce := NewNonce()
+	session.Set(key, []byte(nonce))
+
+	return nonce, nil
+}
+
+// LoadNonce Take the nonce of the pending login out of the session so that
+// it can only be used once.
+func LoadNonce(session SessionManager, key string) (string, error) {
+	if session == nil {
+		return "", &ErrNoSession{}
+	}
+
+	nonce := session.Get(key)
+	if len(nonce) == 0 {
+		return "", &ErrNoSessionData{key}
+	}
+
+	if e := session.Remove(key); e != nil {
+		Log.Warnf("%v", e.Error())
+	}
+
+	return string(nonce), nil
+}
+
+// VerifyNonce Check the nonce claim of an ID token matches the nonce sent
+// with the login.
+func VerifyNonce(nonce string, claim interface{}) error {
+	value, ok := claim.(string)
+	if !ok || nonce == "" || value != nonce {
+		return &ErrNonceMismatch{fmt.Sprintf("%v", claim)}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+)
+
+// PKCEMode Whether to use Proof Key for Code Exchange (RFC 7636) to protect
+// the authorization code from interception.
+type PKCEMode int
+
+const (
+	// PKCEAuto Use PKCE when the discovery document advertises S256, and
+	// then require the code verifier on exchange.
+	PKCEAuto PKCEMode = iota
+	// PKCEDisabled Never use PKCE.
+	PKCEDisabled
+	// PKCERequired Always use PKCE, fail when the discovery document does not
+	// advertise S256.
+	PKCERequired
+)
+
+const pkceMethodS256 = "S256"
+
+// CodeChallengeS256 Transform the code verifier into the code challenge.
+func CodeChallengeS256(verifier string) string {
+	hashed := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hashed[:])
+}
+
+// NewCodeVerifier A high-entropy random string of 43 characters, made of
+// only the unreserved characters RFC 7636 allows.
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, e := rand.Read(b); e != nil {
+		return "", fmt.Errorf(stderr.Random, e.Error())
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge Generate a code verifier for a login, keep it in the session
+// under the key, and return the query parameters to add to the authorization
+// URL. Nothing is returned when PKCE is not in use.
+func PKCEChallenge(mode PKCEMode, doc *DiscoverDoc, session SessionManager, key string) (string, error) {
+	use, e1 := mode.use(doc)
+	if !use {
+		return "", e1
+	}
+
+	if session == nil {
+		return "", &ErrNoSession{}
+	}
+
+	verifier, e2 := NewCodeVerifier()
+	if e2 != nil {
+		return "", e2
+	}
+
+	session.Set(key, []byte(verifier))
+
+	return "&code_challenge=" + CodeChallengeS256(verifier) + "&code_challenge_method=" + pkceMethodS256, nil
+}
+
+// PKCEVerifier Return the parameter to add to the token request, taking the
+// code verifier out of the session so that it can only be used once. Nothing
+// is returned when PKCE is not in use.
+func PKCEVerifier(mode PKCEMode, doc *DiscoverDoc, session SessionManager, key string) (string, error) {
+	use, e1 := mode.use(doc)
+	if !use {
+		return "", e1
+	}
+
+	if session == nil {
+		return "", &ErrNoSession{}
+	}
+
+	verifier := session.Get(key)
+	if len(verifier) == 0 {
+		return "", &ErrNoSessionData{key}
+	}
+
+	if e := session.Remove(key); e != nil {
+		Log.Warnf("%v", e.Error())
+	}
+
+	return "&code_verifier=" + string(verifier), nil
+}
+
+// use Whether PKCE should be used with the identity provider described by
+// the discovery document.
+func (m PKCEMode) use(doc *DiscoverDoc) (bool, error) {
+	supported := doc != nil && slices.Contains(doc.CodeChallengeMethodsSupported, pkceMethodS256)
+
+	switch m {
+	case PKCEDisabled:
+		return false, nil
+	case PKCERequired:
+		if !supported {
+			return false, fmt.Errorf("%v", stderr.PKCENotSupported)
+		}
+	}
+
+	return supported, nil
+}
@@ -0,0 +1,118 @@
+package apple
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kohirens/sso"
+	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	envDiscoverDocURL     = "APPLE_DISCOVERY_DOC_URL"
+	envOIDCClientID       = "APPLE_OIDC_CLIENT_ID"
+	envOIDCKeyID          = "APPLE_OIDC_KEY_ID"
+	envOIDCPrivateKeyFile = "APPLE_OIDC_PRIVATE_KEY_FILE"
+	envOIDCRedirectURIs   = "APPLE_OIDC_REDIRECT_URIS"
+	envOIDCTeamID         = "APPLE_OIDC_TEAM_ID"
+
+	keyDiscoveryDoc = "apple_discovery_document"
+	keyCertificate  = "apple_certificate"
+
+	// clientSecretAudience The aud claim Apple expects in a client secret.
+	clientSecretAudience = "https://appleid.apple.com"
+	// clientSecretExpiration Seconds a generated client secret is valid,
+	// Apple allows up to 6 months, but they are cheap to make.
+	clientSecretExpiration = 300
+)
+
+// Log A logger that follows the Kohirens standard of logging; where a human
+// comprehensible error message is treated as equal to an error code. Having
+// either one should point directly to where to problem in the code lies. In
+// fact the error code can be omitted if so desired.
+var Log = &logger.Standard{}
+
+// NewAuth Provider Authentication object using credentials found in the
+// environment.
+//
+//	Will look for:
+//	  APPLE_OIDC_CLIENT_ID
+//	  APPLE_OIDC_KEY_ID
+//	  APPLE_OIDC_PRIVATE_KEY_FILE
+//	  APPLE_OIDC_REDIRECT_URIS
+//	  APPLE_OIDC_TEAM_ID
+func NewAuth() (*OAuth2, error) {
+	clientID, ok1 := os.LookupEnv(envOIDCClientID)
+	if !ok1 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCClientID)
+	}
+
+	teamID, ok2 := os.LookupEnv(envOIDCTeamID)
+	if !ok2 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCTeamID)
+	}
+
+	keyID, ok3 := os.LookupEnv(envOIDCKeyID)
+	if !ok3 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCKeyID)
+	}
+
+	keyFile, ok4 := os.LookupEnv(envOIDCPrivateKeyFile)
+	if !ok4 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCPrivateKeyFile)
+	}
+
+	redirectURI := os.Getenv(envOIDCRedirectURIs)
+	if redirectURI == "" {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCRedirectURIs)
+	}
+
+	keyData, e1 := os.ReadFile(keyFile)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ReadPrivateKey, e1.Error())
+	}
+
+	privateKey, e2 := LoadPrivateKey(keyData)
+	if e2 != nil {
+		return nil, e2
+	}
+
+	return &OAuth2{
+		ClientID:    clientID,
+		KeyID:       keyID,
+		PrivateKey:  privateKey,
+		RedirectURI: redirectURI,
+		TeamID:      teamID,
+	}, nil
+}
+
+// NewProvider Initialize a Sign in with Apple provider to authenticate a
+// client requesting access to your application.
+func NewProvider(client sso.HttpClient, store storage.Storage, session sso.SessionManager, prefix string) (*Provider, error) {
+	oauth2, e1 := NewAuth()
+	if e1 != nil {
+		return nil, e1
+	}
+
+	ap := &Provider{
+		Login:        sso.Login{Store: store},
+		DiscoveryDoc: &sso.DiscoverDoc{},
+		OAuth2:       oauth2,
+		Scopes:       []string{"name", "email"},
+		State:        sso.NewState(),
+		client:       client,
+		session:      session,
+		Prefix:       prefix,
+	}
+
+	if e := ap.LoadDiscoveryDoc(); e != nil {
+		return ap, e
+	}
+
+	if e := ap.LoadCertificate(); e != nil {
+		return ap, e
+	}
+
+	return ap, nil
+}
@@ -0,0 +1,196 @@
+package apple
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso"
+)
+
+// OAuth2 The credentials of the Services ID and the private key registered
+// with Apple for this application. These will come from the environment this
+// application runs in.
+type OAuth2 struct {
+	// ClientID The Services ID, for example com.example.web.
+	ClientID string
+	// KeyID Identifier of the private key made in the Apple developer account.
+	KeyID string
+	// PrivateKey The .p8 key used to sign the client secret.
+	PrivateKey  *ecdsa.PrivateKey
+	RedirectURI string
+	// TeamID The Apple developer team the Services ID belongs to.
+	TeamID string
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`            // AccessToken A token used to access allowed data, currently Apple provides no data.
+	ExpiresIn    int    `json:"expires_in"`              // ExpiresIn The remaining lifetime of the access token in seconds.
+	IDToken      string `json:"id_token"`                // IDToken A JWT that contains the users identity information.
+	TokenType    string `json:"token_type"`              // TokenType Identifies the type of token returned. At this time, this field always has the value Bearer.
+	RefreshToken string `json:"refresh_token,omitempty"` // RefreshToken Used to obtain new ID tokens, only returned when exchanging an authorization code.
+	info         *jwt.Info
+	claims       *sso.IDTokenClaims
+	Exp          *time.Time
+}
+
+// User The name and email Apple posts to the redirect URI along with the
+// authorization code.
+//
+//	NOTE: Apple only sends this the first time the user consents to your
+//	application, so it must be saved then. It is never included in the ID
+//	token.
+type User struct {
+	Name  UserName `json:"name"`
+	Email string   `json:"email"`
+}
+
+type UserName struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+// Claims Decode the claims of the ID token, this is only done once.
+func (t *Token) Claims() (*sso.IDTokenClaims, error) {
+	if t.claims == nil {
+		info, e1 := t.IDTokenInfo()
+		if e1 != nil {
+			return nil, e1
+		}
+
+		claims, e2 := sso.LoadIDTokenClaims(info.Payload)
+		if e2 != nil {
+			return nil, e2
+		}
+		t.claims = claims
+	}
+
+	return t.claims, nil
+}
+
+func (t *Token) Expired() bool {
+	return t.Exp != nil && t.Exp.Before(time.Now().UTC())
+}
+
+// IDTokenInfo Convert the ID token string into code we can use to extract
+// values that will be used to validate it.
+// NOTE: This is NOT what performs validation, but aids in the process.
+func (t *Token) IDTokenInfo() (*jwt.Info, error) {
+	if t.info == nil {
+		info, e1 := jwt.Parse(t.IDToken)
+		if e1 != nil {
+			return nil, e1
+		}
+		t.info = info
+	}
+	return t.info, nil
+}
+
+// ClientSecret Generate the client secret Apple requires when talking to its
+// token endpoint, a JWT signed with the private key using ES256, see:
+// https://developer.apple.com/documentation/accountorganizationaldatasharing/creating-a-client-secret
+func (o *OAuth2) ClientSecret(now time.Time) (string, error) {
+	if o.PrivateKey == nil {
+		return "", fmt.Errorf("%v", stderr.NoPrivateKey)
+	}
+
+	encHeader, e1 := jwt.Encode(jwt.ClaimSet{"alg": "ES256", "kid": o.KeyID})
+	if e1 != nil {
+		return "", e1
+	}
+
+	encPayload, e2 := jwt.Encode(jwt.ClaimSet{
+		"iss": o.TeamID,
+		"iat": jwt.FormatTime(now),
+		"exp": jwt.FormatTime(now.Add(clientSecretExpiration * time.Second)),
+		"aud": clientSecretAudience,
+		"sub": o.ClientID,
+	})
+	if e2 != nil {
+		return "", e2
+	}
+
+	signature, e3 := signES256(o.PrivateKey, []byte(encHeader+"."+encPayload))
+	if e3 != nil {
+		return "", e3
+	}
+
+	return encHeader + "." + encPayload + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// LoadPrivateKey Load the PKCS#8 private key in PEM format, as downloaded
+// from Apple in a .p8 file.
+func LoadPrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%v", stderr.DecodePrivateKey)
+	}
+
+	key, e1 := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsePrivateKey, e1.Error())
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%v", stderr.NotECPrivateKey)
+	}
+
+	return ecKey, nil
+}
+
+// LoadUser Decode the user JSON Apple posts on first consent.
+func LoadUser(data []byte) (*User, error) {
+	user := &User{}
+	if e := json.Unmarshal(data, user); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return user, nil
+}
+
+// loadToken Convert token data to a Token, then close it.
+func loadToken(rc io.ReadCloser) (*Token, error) {
+	defer func() { _ = rc.Close() }()
+
+	resBody, e2 := io.ReadAll(rc)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ReadResponse, e2.Error())
+	}
+
+	token := &Token{}
+	if e := json.Unmarshal(resBody, token); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	exp := time.Now().UTC().Add(time.Duration(token.ExpiresIn) * time.Second)
+	token.Exp = &exp
+
+	return token, nil
+}
+
+// signES256 Sign data with ECDSA using P-256 and SHA-256, returning the
+// signature as the R and S values concatenated as JWS requires.
+func signES256(key *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	hashed := sha256.Sum256(data)
+
+	r, s, e1 := ecdsa.Sign(rand.Reader, key, hashed[:])
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.SignES256, e1.Error())
+	}
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signature, nil
+}
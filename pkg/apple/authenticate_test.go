@@ -0,0 +1,114 @@
+package apple
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso/internal/ssotest"
+)
+
+func TestOAuth2_ClientSecret(t *testing.T) {
+	keyData, _ := os.ReadFile(fixtureDir + "/AuthKey_TEST123456.p8")
+	fixedKey, _ := LoadPrivateKey(keyData)
+
+	tests := []struct {
+		name    string
+		key     *ecdsa.PrivateKey
+		wantErr bool
+	}{
+		{"no_key", nil, true},
+		{"good", fixedKey, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &OAuth2{
+				ClientID:   "com.example.web",
+				KeyID:      "TEST123456",
+				PrivateKey: tt.key,
+				TeamID:     "TEAM123456",
+			}
+
+			got, err := o.ClientSecret(time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ClientSecret() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			info, e1 := jwt.Parse(got)
+			if e1 != nil {
+				t.Errorf("ClientSecret() is not a JWT: %v", e1)
+				return
+			}
+
+			if info.Header["kid"] != "TEST123456" || info.Payload["iss"] != "TEAM123456" || info.Payload["sub"] != "com.example.web" {
+				t.Errorf("ClientSecret() has the wrong claims %v %v", info.Header, info.Payload)
+				return
+			}
+
+			sig, _ := base64.RawURLEncoding.DecodeString(info.EncodedSignature)
+			hashed := sha256.Sum256([]byte(info.EncodedHeader + "." + info.EncodedPayload))
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if !ecdsa.Verify(&tt.key.PublicKey, hashed[:], r, s) {
+				t.Errorf("ClientSecret() signature did not verify")
+			}
+		})
+	}
+}
+
+func TestLoadUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantFirst string
+		wantErr   bool
+	}{
+		{"malformed", `{"name":`, "", true},
+		{"good", `{"name":{"firstName":"Crowbar","lastName":"Jones"},"email":"crowbar@example.com"}`, "Crowbar", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadUser([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && got.Name.FirstName != tt.wantFirst {
+				t.Errorf("LoadUser() got %v, want %v", got.Name.FirstName, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	ecKey, _ := os.ReadFile(fixtureDir + "/AuthKey_TEST123456.p8")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"not_pem", []byte(strings.Repeat("a", 10)), true},
+		{"not_pkcs8", ssotest.Key, true},
+		{"good", ecKey, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadPrivateKey(tt.data); (err != nil) != tt.wantErr {
+				t.Errorf("LoadPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
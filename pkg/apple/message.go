@@ -0,0 +1,61 @@
+package apple
+
+var stderr = struct {
+	DecodeJSON,
+	DecodePrivateKey,
+	DiscoveryTokenURI,
+	EncodeJSON,
+	IDTokenNoEmail,
+	IDTokenNoSub,
+	MissEnvVar,
+	NoAuthEndpoint,
+	NoCerts,
+	NoPrivateKey,
+	NotECPrivateKey,
+	OAuth2Nil,
+	ParsePrivateKey,
+	ParsingIDToken,
+	ReadPrivateKey,
+	ReadResponse,
+	Response,
+	SignatureVerify,
+	SignES256,
+	SignOut,
+	ValidateTokenAud,
+	ValidateTokenExp,
+	ValidateTokenIss,
+	ValidateTokenNil string
+}{
+	DecodeJSON:        "could not decode JSON: %v",
+	DecodePrivateKey:  "could not decode the private key PEM data",
+	DiscoveryTokenURI: "discovery document token endpoint is empty",
+	EncodeJSON:        "unable encode JSON: %v",
+	IDTokenNoEmail:    "no email claim found in payload",
+	IDTokenNoSub:      "no sub claim found in payload",
+	MissEnvVar:        "missing env var: %v",
+	NoAuthEndpoint:    "discovery document authorization endpoint is empty",
+	NoCerts:           "no certificates to validate token",
+	NoPrivateKey:      "no private key to sign the client secret",
+	NotECPrivateKey:   "the private key is not an elliptic curve key",
+	OAuth2Nil:         "no oauth2 credentials are set",
+	ParsePrivateKey:   "could not parse private key: %v",
+	ParsingIDToken:    "error parsing ID token: %v",
+	ReadPrivateKey:    "could not read private key file: %v",
+	ReadResponse:      "could not read response: %v",
+	Response:          "not the expected response: %v",
+	SignatureVerify:   "signature verification failed: %v",
+	SignES256:         "could not sign with ES256: %v",
+	SignOut:           "signing out failed: %v",
+	ValidateTokenAud:  "invalid aud\nret-aud: %v\norg-aud: %v",
+	ValidateTokenExp:  "token has expired",
+	ValidateTokenIss:  "invalid iss: %v",
+	ValidateTokenNil:  "token is nil",
+}
+
+var stdout = struct {
+	TokenExp,
+	TokenUri string
+}{
+	TokenExp: "apple has provided a token that expires in %v seconds",
+	TokenUri: "Apple OIDC Token URI: %v",
+}
@@ -0,0 +1,634 @@
+package apple
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kohirens/sso"
+)
+
+type Provider struct {
+	sso.Login
+	// DiscoveryDoc contains well known info about the Apple OIDC service.
+	DiscoveryDoc *sso.DiscoverDoc `json:"discoveryDocument"`
+	JWKs         *sso.JwksUriv3   `json:"keys"`
+	// OAuth2 The Services ID, key, and RedirectURI registered with Apple for
+	// this application. These will come from the environment this
+	// application runs in.
+	OAuth2 *OAuth2
+	// PKCE Whether to use a code challenge, by default only when the
+	// discovery document advertises the S256 method.
+	PKCE   sso.PKCEMode `json:"pkce"`
+	Scopes []string     `json:"scopes"`
+	State  string       `json:"state"`
+	Token  *Token       `json:"credentials"`
+	// User The name and email sent on first consent, nil otherwise.
+	User    *User `json:"user"`
+	client  sso.HttpClient
+	Prefix  string
+	session sso.SessionManager
+}
+
+var _ sso.OIDCProvider = (*Provider)(nil)
+
+// Application The Services ID registered with Apple.
+func (p *Provider) Application() string {
+	return p.OAuth2.ClientID
+}
+
+// Authenticated Indicates if the client has been successfully authenticated
+// by Apple.
+func (p *Provider) Authenticated() bool {
+	return p.Token != nil && !p.Token.Expired()
+}
+
+// AuthLink Generate a link to authenticate with the provider.
+//
+//	Apple requires response_mode=form_post when requesting the name or email
+//	scope, so the callback will be a POST request with the code, state, and on
+//	first consent, the user in the form body.
+func (p *Provider) AuthLink(loginHint string) (string, error) {
+	epAuthentication := p.DiscoveryDoc.AuthorizationEndpoint
+	if epAuthentication == "" {
+		return "", fmt.Errorf("%v", stderr.NoAuthEndpoint)
+	}
+
+	if e := sso.SaveState(p.session, p.sessionKey(sso.SessionState), p.State); e != nil {
+		return "", e
+	}
+
+	nonce, e1 := sso.SaveNonce(p.session, p.sessionKey(sso.SessionNonce))
+	if e1 != nil {
+		return "", e1
+	}
+
+	uri := fmt.Sprintf(
+		"%v?response_type=code&response_mode=form_post&scope=%v&redirect_uri=%v&client_id=%v&state=%v&nonce=%v",
+		epAuthentication,
+		strings.Join(p.Scopes, "%20"),
+		url.QueryEscape(p.OAuth2.RedirectURI),
+		url.QueryEscape(p.OAuth2.ClientID),
+		p.State,
+		nonce,
+	)
+
+	if loginHint != "" {
+		uri = uri + "&login_hint=" + url.QueryEscape(loginHint)
+	}
+
+	pkce, e2 := sso.PKCEChallenge(p.PKCE, p.DiscoveryDoc, p.session, p.sessionKey(sso.SessionCodeVerifier))
+	if e2 != nil {
+		return "", e2
+	}
+	uri = uri + pkce
+
+	Log.Dbugf("Apple OIDC Auth URI: %s", uri)
+
+	return uri, nil
+}
+
+// Certificate Download the JWKs for validating ID tokens from Apple.
+func (p *Provider) Certificate() error {
+	return sso.RefreshDocument(
+		p.client,
+		p.Store,
+		p.location(keyCertificate),
+		p.DiscoveryDoc.JwksUri,
+		p.setCertificate,
+	)
+}
+
+// ClientEmail Return the logged in clients email address. This may be a
+// private relay address when the user chose to hide their email.
+func (p *Provider) ClientEmail() string {
+	email, e1 := p.ParseClientEmail()
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+	}
+
+	return email
+}
+
+// ClientID The sub claim of the ID token, a unique, stable identifier for
+// the user that is scoped to your Apple developer team.
+func (p *Provider) ClientID() string {
+	clientID, e1 := p.ParseClientID()
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+	}
+
+	return clientID
+}
+
+// ExchangeCodeForToken Trade the authorization code Apple posted to the
+// callback for an ID and refresh token.
+func (p *Provider) ExchangeCodeForToken(state, code string) error {
+	if e := p.VerifyState(state); e != nil {
+		return e
+	}
+
+	if p.OAuth2 == nil {
+		return fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	nonce, e3 := sso.LoadNonce(p.session, p.sessionKey(sso.SessionNonce))
+	if e3 != nil {
+		return e3
+	}
+
+	pkce, e2 := sso.PKCEVerifier(p.PKCE, p.DiscoveryDoc, p.session, p.sessionKey(sso.SessionCodeVerifier))
+	if e2 != nil {
+		return e2
+	}
+
+	reqBody := fmt.Sprintf(
+		"code=%v&redirect_uri=%v&grant_type=authorization_code%v",
+		url.QueryEscape(code),
+		url.QueryEscape(p.OAuth2.RedirectURI),
+		pkce,
+	)
+
+	token, e1 := p.requestToken("authorization_code", reqBody)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := p.ValidateToken(token); e != nil {
+		return e
+	}
+
+	// Verify the nonce claim matches the nonce sent with the login, so that
+	// an ID token cannot be replayed.
+	claims, e4 := token.Claims()
+	if e4 != nil {
+		return fmt.Errorf(stderr.ParsingIDToken, e4.Error())
+	}
+
+	if e := sso.VerifyNonce(nonce, claims.Nonce); e != nil {
+		return e
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookLogin, id, p.LoginInfo(), nil); e != nil {
+		return e
+	}
+
+	p.Token = token
+
+	Log.Dbugf(stdout.TokenExp, p.Token.ExpiresIn)
+
+	return nil
+}
+
+// IDTokenClaims Return the claims of the ID token.
+func (p *Provider) IDTokenClaims() (*sso.IDTokenClaims, error) {
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	claims, e1 := p.Token.Claims()
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	return claims, nil
+}
+
+// LoadCertificate Load the Apple JWKs, try from cache first, then download
+// from the internet if that fails.
+func (p *Provider) LoadCertificate() error {
+	return sso.LoadDocument(
+		p.client,
+		p.Store,
+		p.location(keyCertificate),
+		p.DiscoveryDoc.JwksUri,
+		p.setCertificate,
+	)
+}
+
+// LoadDiscoveryDoc Load the Apple discovery document, try from cache first,
+// then download from the internet if that fails.
+func (p *Provider) LoadDiscoveryDoc() error {
+	return sso.LoadDocument(
+		p.client,
+		p.Store,
+		p.location(keyDiscoveryDoc),
+		os.Getenv(envDiscoverDocURL),
+		func(data []byte) error {
+			doc, e := sso.LoadDiscoverDoc(data)
+			if e != nil {
+				return e
+			}
+			p.DiscoveryDoc = doc
+			return nil
+		},
+	)
+}
+
+// LoadLoginInfo retrieve previous login info from storage.
+//
+//	NOTE: This requires the client to have consented beforehand. The
+//	best time to call this method is during or right after the callback.
+func (p *Provider) LoadLoginInfo(deviceID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return p.Open(id, deviceID, sessionID, userAgent)
+}
+
+// Name ID of the OIDC provider.
+func (p *Provider) Name() string {
+	return "apple"
+}
+
+// ParseClientEmail Return the logged in clients email address from the ID
+// token, or an error when there is no token, or it has no email.
+func (p *Provider) ParseClientEmail() (string, error) {
+	claims, e1 := p.IDTokenClaims()
+	if e1 != nil {
+		return "", e1
+	}
+
+	if claims.Email == "" {
+		return "", fmt.Errorf("%v", stderr.IDTokenNoEmail)
+	}
+
+	return claims.Email, nil
+}
+
+// ParseClientID Return the sub claim of the ID token, or an error when there
+// is no token, or it has no sub.
+func (p *Provider) ParseClientID() (string, error) {
+	claims, e1 := p.IDTokenClaims()
+	if e1 != nil {
+		return "", e1
+	}
+
+	if claims.Subject == "" {
+		return "", fmt.Errorf("%v", stderr.IDTokenNoSub)
+	}
+
+	return claims.Subject, nil
+}
+
+// ParseUser Decode the user form field Apple posts to the callback on first
+// consent and keep it on the provider. Call this before
+// ExchangeCodeForToken, it is the only chance to get the user's name.
+func (p *Provider) ParseUser(data string) error {
+	if data == "" {
+		return nil
+	}
+
+	user, e1 := LoadUser([]byte(data))
+	if e1 != nil {
+		return e1
+	}
+
+	p.User = user
+
+	return nil
+}
+
+// RefreshToken Validate the refresh token with Apple, which returns a new ID
+// token, but not a new refresh token.
+func (p *Provider) RefreshToken() error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	reqBody := fmt.Sprintf(
+		"refresh_token=%v&grant_type=refresh_token",
+		url.QueryEscape(p.Token.RefreshToken),
+	)
+
+	token, e1 := p.requestToken("refresh_token", reqBody)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := p.ValidateToken(token); e != nil {
+		return e
+	}
+
+	if token.RefreshToken == "" {
+		token.RefreshToken = p.Token.RefreshToken
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookRefresh, id, p.LoginInfo(), nil); e != nil {
+		return e
+	}
+
+	p.Token = token
+
+	return nil
+}
+
+// RegisterLoginInfo Register new login information.
+//
+//	NOTE: This is the only time the user agent is set on a device.
+func (p *Provider) RegisterLoginInfo(accountID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	// Token must be set.
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return nil, e
+	}
+
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return p.Register(id, accountID, sessionID, userAgent)
+}
+
+// RevokeDevice Sign the device out and stop trusting it, so that it must
+// authenticate again, then save the login info.
+func (p *Provider) RevokeDevice(deviceID string) error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Revoke(id, deviceID)
+}
+
+// SaveLoginInfo Save info for retrieval without hitting Apple servers.
+func (p *Provider) SaveLoginInfo() error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Save(id)
+}
+
+// SignOut Revoke the refresh token with Apple, so the user must consent
+// again to sign in to your application. Will also remove any data stored in
+// the session, and unbind the session from the device.
+func (p *Provider) SignOut() error {
+	var revoke func() error
+	if p.Token != nil {
+		revoke = p.revokeToken
+	}
+
+	id, _ := p.identity(p.Token)
+	err := p.Login.SignOut(id, revoke)
+
+	if e := sso.ClearSession(p.session, p.sessionKey); e != nil {
+		Log.Warnf(stderr.SignOut, e.Error())
+	}
+
+	p.Token = nil
+
+	return err
+}
+
+// UpdateLoginInfo Address changes in the users login information, list the
+// devices, last activity time, etc.
+//
+//	NOTE: Never update the provider ClientID nor the user agent on the device,
+//	these are only set on registration.
+func (p *Provider) UpdateLoginInfo(deviceID, sessionID, userAgent string) error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return e
+	}
+
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Update(id, deviceID, sessionID, userAgent)
+}
+
+// UserInfo Get the profile of the client from the ID token. Apple has no
+// userinfo endpoint, so the name is only known when the user was posted to
+// the callback on first consent, see ParseUser, or from the login info it was
+// saved with.
+func (p *Provider) UserInfo() (*sso.Profile, error) {
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	info, e1 := p.Token.IDTokenInfo()
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	profile, e2 := sso.ProfileFromClaims(info.Payload)
+	if e2 != nil {
+		return nil, e2
+	}
+
+	switch li := p.LoginInfo(); {
+	case p.User != nil:
+		profile.GivenName = p.User.Name.FirstName
+		profile.FamilyName = p.User.Name.LastName
+	case li != nil:
+		profile.GivenName = li.GivenName
+		profile.FamilyName = li.FamilyName
+	}
+	if profile.GivenName != "" || profile.FamilyName != "" {
+		profile.Name = strings.TrimSpace(profile.GivenName + " " + profile.FamilyName)
+	}
+
+	return profile, nil
+}
+
+// ValidateToken Validate an ID token came from Apple.
+// https://developer.apple.com/documentation/sign_in_with_apple/sign_in_with_apple_rest_api/verifying_a_user
+func (p *Provider) ValidateToken(token *Token) error {
+	reason, err := p.validateToken(token)
+	sso.CountValidation(p.Name(), reason)
+
+	return err
+}
+
+// VerifyState Verify the state returned from the request matches the
+// original value sent.
+func (p *Provider) VerifyState(returnedState string) error {
+	return sso.VerifyState(p.session, p.sessionKey(sso.SessionState), returnedState)
+}
+
+// identity Who the token signed in, filled in as far as the token allows,
+// with an error when that is not enough to find their login info.
+func (p *Provider) identity(token *Token) (*sso.Identity, error) {
+	id := &sso.Identity{Provider: p.Name()}
+	if token == nil {
+		return id, &ErrNoToken{}
+	}
+
+	id.RefreshToken = token.RefreshToken
+
+	claims, e1 := token.Claims()
+	if e1 != nil {
+		return id, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	id.Claims = claims
+	id.Email = claims.Email
+	id.Subject = claims.Subject
+
+	if claims.Subject == "" {
+		return id, fmt.Errorf("%v", stderr.IDTokenNoSub)
+	}
+
+	id.Location = p.location("logins/" + claims.Subject)
+
+	if p.User != nil {
+		id.FamilyName = p.User.Name.LastName
+		id.GivenName = p.User.Name.FirstName
+	}
+
+	return id, nil
+}
+
+// location Return the storage location.
+func (p *Provider) location(filename string) string {
+	return sso.Location(p.Prefix, filename)
+}
+
+// requestToken Post to the token endpoint, authenticating with a freshly
+// generated client secret, and time it under the grant.
+func (p *Provider) requestToken(grant, reqBody string) (*Token, error) {
+	uri := p.DiscoveryDoc.TokenEndpoint
+	if uri == "" {
+		return nil, fmt.Errorf("%v", stderr.DiscoveryTokenURI)
+	}
+
+	Log.Dbugf(stdout.TokenUri, uri)
+
+	clientSecret, e1 := p.OAuth2.ClientSecret(time.Now())
+	if e1 != nil {
+		return nil, e1
+	}
+
+	reqBody = fmt.Sprintf(
+		"client_id=%v&client_secret=%v&%v",
+		url.QueryEscape(p.OAuth2.ClientID),
+		clientSecret,
+		reqBody,
+	)
+
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	start := time.Now()
+	res, e2 := sso.SendWithRetry(p.client, "POST", uri, []byte(reqBody), headers, http.StatusOK, 3)
+	sso.ObserveTokenRequest(p.Name(), grant, start, res)
+	if res == nil {
+		return nil, fmt.Errorf(stderr.Response, e2)
+	}
+
+	return loadToken(res.Body)
+}
+
+// refreshCertificate Download the JWKs again, for when the keys were rotated.
+func (p *Provider) refreshCertificate() (*sso.JwksUriv3, error) {
+	if e := p.Certificate(); e != nil {
+		return nil, e
+	}
+
+	return p.JWKs, nil
+}
+
+// revokeToken Revoke the refresh token, or the access token when there is no
+// refresh token, at the Apple revocation endpoint.
+func (p *Provider) revokeToken() error {
+	clientSecret, e1 := p.OAuth2.ClientSecret(time.Now())
+	if e1 != nil {
+		return e1
+	}
+
+	token, hint := p.Token.RefreshToken, "refresh_token"
+	if token == "" {
+		token, hint = p.Token.AccessToken, "access_token"
+	}
+
+	reqBody := fmt.Sprintf(
+		"client_id=%v&client_secret=%v&token=%v&token_type_hint=%v",
+		url.QueryEscape(p.OAuth2.ClientID),
+		clientSecret,
+		url.QueryEscape(token),
+		hint,
+	)
+
+	return sso.RevokeToken(p.client, p.DiscoveryDoc.RevocationEndpoint, reqBody, nil)
+}
+
+// sessionKey Where a value is kept in the session for this provider.
+func (p *Provider) sessionKey(name string) string {
+	return sso.SessionTokenApple + name
+}
+
+// setCertificate Decode the JWKs used to validate ID tokens.
+func (p *Provider) setCertificate(data []byte) error {
+	jwks, e := sso.LoadJwksUriv3(data)
+	if e != nil {
+		return e
+	}
+
+	p.JWKs = jwks
+
+	return nil
+}
+
+// validateToken Validate the ID token, returning why it failed for the
+// metrics.
+func (p *Provider) validateToken(token *Token) (string, error) {
+	if token == nil {
+		return sso.TokenReasonMalformed, fmt.Errorf("%v", stderr.ValidateTokenNil)
+	}
+
+	info, e1 := token.IDTokenInfo()
+	if e1 != nil {
+		return sso.TokenReasonMalformed, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	if p.JWKs == nil {
+		return sso.TokenReasonNoKeys, fmt.Errorf("%v", stderr.NoCerts)
+	}
+
+	// 1. Verify the signature using one of Apple's public keys.
+	if e := sso.VerifySignature(token.IDToken, info.Header, p.JWKs, p.DiscoveryDoc.JwksUri, p.refreshCertificate); e != nil {
+		return sso.TokenReasonSignature, fmt.Errorf(stderr.SignatureVerify, e.Error())
+	}
+
+	// 2. Verify that the iss field contains https://appleid.apple.com.
+	iss, ok1 := info.Payload["iss"]
+	if !ok1 || p.DiscoveryDoc.Issuer != iss {
+		return sso.TokenReasonIssuer, fmt.Errorf(stderr.ValidateTokenIss, iss)
+	}
+
+	claims, e2 := token.Claims()
+	if e2 != nil {
+		return sso.TokenReasonMalformed, fmt.Errorf(stderr.ParsingIDToken, e2.Error())
+	}
+
+	// 3. Verify that the aud field is the developer’s client_id, it may be a
+	// single string or an array of strings.
+	if !claims.Audience.Contains(p.OAuth2.ClientID) {
+		return sso.TokenReasonAudience, fmt.Errorf(stderr.ValidateTokenAud, claims.Audience, p.OAuth2.ClientID)
+	}
+
+	// 4. Verify that the time is earlier than the exp value of the token.
+	if time.Unix(claims.Expires, 0).Before(time.Now()) {
+		return sso.TokenReasonExpired, fmt.Errorf("%v", stderr.ValidateTokenExp)
+	}
+
+	return "", nil
+}
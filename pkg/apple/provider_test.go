@@ -0,0 +1,239 @@
+package apple
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso"
+	"github.com/kohirens/sso/internal/ssotest"
+	"github.com/kohirens/stdlib/test"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	fixtureDir = "testdata"
+	tmpDir     = "tmp"
+	fixState   = "abcdefghijklmnopqrstuvwxyz1234"
+	fixNonce   = "n-0123456789"
+)
+
+func TestMain(m *testing.M) {
+	test.ResetDir(tmpDir, 0777)
+
+	os.Exit(m.Run())
+}
+
+// fixtureProvider A provider with the discovery document and JWKs loaded
+// from the testdata directory.
+func fixtureProvider(client sso.HttpClient) *Provider {
+	b1, _ := os.ReadFile(fixtureDir + "/apple_discovery_document.json")
+	dd, _ := sso.LoadDiscoverDoc(b1)
+	jwks, _ := sso.LoadJwksUriv3(ssotest.Certificate)
+	keyData, _ := os.ReadFile(fixtureDir + "/AuthKey_TEST123456.p8")
+	key, _ := LoadPrivateKey(keyData)
+
+	return &Provider{
+		DiscoveryDoc: dd,
+		JWKs:         jwks,
+		OAuth2: &OAuth2{
+			ClientID:    "com.example.web",
+			KeyID:       "TEST123456",
+			PrivateKey:  key,
+			RedirectURI: "https://example.com/callback",
+			TeamID:      "TEAM123456",
+		},
+		Scopes:  []string{"name", "email"},
+		State:   fixState,
+		client:  client,
+		session: ssotest.Session{},
+	}
+}
+
+func TestProvider_AuthLink(t *testing.T) {
+	p := fixtureProvider(nil)
+
+	got, err := p.AuthLink("user@example.com")
+	if err != nil {
+		t.Errorf("AuthLink() error = %v", err)
+		return
+	}
+
+	nonce := string(p.session.Get(p.sessionKey(sso.SessionNonce)))
+	for _, want := range []string{"response_mode=form_post", "scope=name%20email", "state=" + fixState, "client_id=com.example.web", "nonce=" + nonce} {
+		if !strings.Contains(got, want) {
+			t.Errorf("AuthLink() = %v, missing %v", got, want)
+		}
+	}
+}
+
+func TestProvider_ValidateToken(t *testing.T) {
+	exp := time.Now().Add(5 * time.Minute).Unix()
+	tests := []struct {
+		name    string
+		payload jwt.ClaimSet
+		wantErr bool
+	}{
+		{
+			"good",
+			jwt.ClaimSet{"iss": "https://appleid.apple.com", "aud": "com.example.web", "exp": exp, "sub": "001234.abc"},
+			false,
+		},
+		{
+			"aud_array",
+			jwt.ClaimSet{"iss": "https://appleid.apple.com", "aud": []string{"com.example.web"}, "exp": exp, "sub": "001234.abc"},
+			false,
+		},
+		{
+			"no_exp",
+			jwt.ClaimSet{"iss": "https://appleid.apple.com", "aud": "com.example.web", "sub": "001234.abc"},
+			true,
+		},
+		{
+			"wrong_iss",
+			jwt.ClaimSet{"iss": "https://accounts.google.com", "aud": "com.example.web", "exp": exp, "sub": "001234.abc"},
+			true,
+		},
+		{
+			"wrong_aud",
+			jwt.ClaimSet{"iss": "https://appleid.apple.com", "aud": "com.example.ios", "exp": exp, "sub": "001234.abc"},
+			true,
+		},
+		{
+			"expired",
+			jwt.ClaimSet{"iss": "https://appleid.apple.com", "aud": "com.example.web", "exp": time.Now().Add(-5 * time.Minute).Unix(), "sub": "001234.abc"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := fixtureProvider(nil)
+
+			if err := p.ValidateToken(&Token{IDToken: ssotest.IDToken(tt.payload)}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_ExchangeCodeForToken(t *testing.T) {
+	idToken := ssotest.IDToken(jwt.ClaimSet{
+		"iss":   "https://appleid.apple.com",
+		"aud":   "com.example.web",
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"sub":   "001234.abc",
+		"email": "crowbar@example.com",
+		"nonce": fixNonce,
+	})
+
+	tests := []struct {
+		name    string
+		state   string
+		nonce   string
+		wantErr bool
+	}{
+		{"state_mismatch", "zyxwvutsrqponmlkjihgfedcba4321", fixNonce, true},
+		{"no_nonce", fixState, "", true},
+		{"nonce_mismatch", fixState, "n-9876543210", true},
+		{"good", fixState, fixNonce, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBody string
+			p := fixtureProvider(&test.MockHttpClient{
+				DoHandler: func(r *http.Request) (*http.Response, error) {
+					b, _ := io.ReadAll(r.Body)
+					gotBody = string(b)
+					res := `{"access_token":"a1","expires_in":3600,"id_token":"` + idToken + `","refresh_token":"r1","token_type":"Bearer"}`
+					return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(res))}, nil
+				},
+			})
+
+			_ = sso.SaveState(p.session, p.sessionKey(sso.SessionState), fixState)
+			if tt.nonce != "" {
+				p.session.Set(p.sessionKey(sso.SessionNonce), []byte(tt.nonce))
+			}
+
+			err := p.ExchangeCodeForToken(tt.state, "c1234")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExchangeCodeForToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if p.session.Get(p.sessionKey(sso.SessionNonce)) != nil {
+				t.Errorf("ExchangeCodeForToken() did not remove the nonce from the session")
+			}
+
+			if !strings.Contains(gotBody, "client_secret=") || !strings.Contains(gotBody, "code=c1234") {
+				t.Errorf("ExchangeCodeForToken() sent %v", gotBody)
+			}
+
+			if p.ClientID() != "001234.abc" || p.ClientEmail() != "crowbar@example.com" {
+				t.Errorf("ExchangeCodeForToken() did not set the token")
+			}
+		})
+	}
+}
+
+func TestProvider_RegisterLoginInfo(t *testing.T) {
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	fixedStore, _ := storage.NewLocalStorage(tmpDir)
+
+	p := &Provider{
+		Token: &Token{
+			info: &jwt.Info{
+				Payload: jwt.ClaimSet{"sub": "register-login-info-good", "email": "test@example.com"},
+			},
+			RefreshToken: "r1234",
+		},
+		Login: sso.Login{Store: fixedStore},
+	}
+	_ = p.ParseUser(`{"name":{"firstName":"Crowbar","lastName":"Jones"}}`)
+
+	li, err := p.RegisterLoginInfo("a1", "4321", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15")
+	if err != nil {
+		t.Errorf("RegisterLoginInfo() error = %v", err)
+		return
+	}
+
+	if li.ClientID != "register-login-info-good" || li.RefreshToken != "r1234" {
+		t.Errorf("RegisterLoginInfo() incorrect info")
+		return
+	}
+
+	// Apple only posts the name on first consent, later logins get it from
+	// the login info.
+	p2 := &Provider{Token: p.Token, Login: sso.Login{Store: fixedStore}}
+
+	got, e2 := p2.LoadLoginInfo(p.DeviceID(), "4321", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15")
+	if e2 != nil || got.AccountID != "a1" || got.GivenName != "Crowbar" || got.FamilyName != "Jones" {
+		t.Errorf("LoadLoginInfo() error = %v", e2)
+		return
+	}
+
+	if profile, e := p2.UserInfo(); e != nil || profile.Name != "Crowbar Jones" {
+		t.Errorf("UserInfo() = %v, error = %v", profile, e)
+	}
+}
+
+func TestProvider_ParseUser(t *testing.T) {
+	p := &Provider{}
+
+	if e := p.ParseUser(""); e != nil || p.User != nil {
+		t.Errorf("ParseUser() should ignore an empty user")
+		return
+	}
+
+	if e := p.ParseUser(`{"name":{"firstName":"Crowbar","lastName":"Jones"}}`); e != nil || p.User.Name.LastName != "Jones" {
+		t.Errorf("ParseUser() error = %v", e)
+	}
+}
@@ -0,0 +1,99 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type OAuth2 struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`            // AccessToken A token that can be sent to the GitHub API.
+	ExpiresIn    int    `json:"expires_in,omitempty"`    // ExpiresIn Seconds the access token is valid, only set when token expiration is enabled for the app.
+	Scope        string `json:"scope"`                   // Scope The scopes granted expressed as a comma separated list.
+	TokenType    string `json:"token_type"`              // TokenType Identifies the type of token returned, always bearer.
+	RefreshToken string `json:"refresh_token,omitempty"` // RefreshToken Only set when token expiration is enabled for the app.
+	Exp          *time.Time
+}
+
+// User The profile of the authenticated user, see:
+// https://docs.github.com/en/rest/users/users#get-the-authenticated-user
+type User struct {
+	// ID The numeric ID of the account, unlike the login it never changes.
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Email An address on the account of the authenticated user, see:
+// https://docs.github.com/en/rest/users/emails#list-email-addresses-for-the-authenticated-user
+type Email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// tokenError GitHub responds to a bad token request with status 200 and
+// this in the body.
+type tokenError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (t *Token) Expired() bool {
+	return t.Exp != nil && t.Exp.Before(time.Now().UTC())
+}
+
+// PrimaryEmail Return the primary email address, only if it has been
+// verified.
+func PrimaryEmail(emails []*Email) (string, error) {
+	for _, e := range emails {
+		if e.Primary {
+			if !e.Verified {
+				return "", fmt.Errorf(stderr.EmailNotVerified, e.Email)
+			}
+			return e.Email, nil
+		}
+	}
+
+	return "", fmt.Errorf("%v", stderr.NoPrimaryEmail)
+}
+
+// loadToken Convert token data to a Token, then close it.
+func loadToken(rc io.ReadCloser) (*Token, error) {
+	defer func() { _ = rc.Close() }()
+
+	resBody, e1 := io.ReadAll(rc)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ReadResponse, e1.Error())
+	}
+
+	te := &tokenError{}
+	if e := json.Unmarshal(resBody, te); e == nil && te.Error != "" {
+		return nil, fmt.Errorf(stderr.TokenError, te.Error, te.Description)
+	}
+
+	token := &Token{}
+	if e := json.Unmarshal(resBody, token); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("%v", stderr.NoAccessToken)
+	}
+
+	// OAuth app tokens do not expire, only set an expiration when given one.
+	if token.ExpiresIn > 0 {
+		exp := time.Now().UTC().Add(time.Duration(token.ExpiresIn) * time.Second)
+		token.Exp = &exp
+	}
+
+	return token, nil
+}
@@ -0,0 +1,15 @@
+package github
+
+import "github.com/kohirens/sso"
+
+type ErrDeviceNotFound = sso.ErrDeviceNotFound
+
+type ErrInvalidState = sso.ErrInvalidState
+
+type ErrNoLoginInfo = sso.ErrNoLoginInfo
+
+type ErrNoToken struct{}
+
+func (e *ErrNoToken) Error() string {
+	return "a token has not been retrieved from github servers"
+}
@@ -0,0 +1,82 @@
+package github
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kohirens/sso"
+	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	envOAuthClientID     = "GITHUB_OAUTH_CLIENT_ID"
+	envOAuthClientSecret = "GITHUB_OAUTH_CLIENT_SECRET"
+	envOAuthRedirectURIs = "GITHUB_OAUTH_REDIRECT_URIS"
+
+	epAPI           = "https://api.github.com"
+	epAuthorization = "https://github.com/login/oauth/authorize"
+	epToken         = "https://github.com/login/oauth/access_token"
+)
+
+// Log A logger that follows the Kohirens standard of logging; where a human
+// comprehensible error message is treated as equal to an error code. Having
+// either one should point directly to where to problem in the code lies. In
+// fact the error code can be omitted if so desired.
+var Log = &logger.Standard{}
+
+// NewAuth Provider Authentication object using credentials found in the
+// environment.
+//
+//	Will look for:
+//	  GITHUB_OAUTH_CLIENT_ID
+//	  GITHUB_OAUTH_CLIENT_SECRET
+//	  GITHUB_OAUTH_REDIRECT_URIS
+func NewAuth() (*OAuth2, error) {
+	clientID, ok1 := os.LookupEnv(envOAuthClientID)
+	if !ok1 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOAuthClientID)
+	}
+
+	clientSecret, ok2 := os.LookupEnv(envOAuthClientSecret)
+	if !ok2 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOAuthClientSecret)
+	}
+
+	redirectURI := os.Getenv(envOAuthRedirectURIs)
+	if redirectURI == "" {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOAuthRedirectURIs)
+	}
+
+	return &OAuth2{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+	}, nil
+}
+
+// NewProvider Initialize a GitHub OAuth2 provider to authenticate a client
+// requesting access to your application.
+//
+//	GitHub is not an OIDC provider, there is no discovery document nor ID
+//	token. The identity of the client is looked up with the access token
+//	instead.
+func NewProvider(client sso.HttpClient, store storage.Storage, session sso.SessionManager, prefix string) (*Provider, error) {
+	oauth2, e1 := NewAuth()
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return &Provider{
+		Login:                 sso.Login{Store: store},
+		APIURL:                epAPI,
+		AuthorizationEndpoint: epAuthorization,
+		OAuth2:                oauth2,
+		Scopes:                []string{"read:user", "user:email"},
+		State:                 sso.NewState(),
+		TokenEndpoint:         epToken,
+		client:                client,
+		session:               session,
+		Prefix:                prefix,
+	}, nil
+}
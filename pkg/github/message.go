@@ -0,0 +1,33 @@
+package github
+
+var stderr = struct {
+	DecodeJSON,
+	EmailNotVerified,
+	EncodeJSON,
+	MissEnvVar,
+	NoAccessToken,
+	NoAuthEndpoint,
+	NoPrimaryEmail,
+	NoTokenEndpoint,
+	NoUser,
+	OAuth2Nil,
+	ReadResponse,
+	Response,
+	SignOut,
+	TokenError string
+}{
+	DecodeJSON:       "could not decode JSON: %v",
+	EmailNotVerified: "primary email %v has not been verified",
+	EncodeJSON:       "unable encode JSON: %v",
+	MissEnvVar:       "missing env var: %v",
+	NoAccessToken:    "no access token in the response",
+	NoAuthEndpoint:   "authorization endpoint is empty",
+	NoPrimaryEmail:   "no primary email found for the user",
+	NoTokenEndpoint:  "token endpoint is empty",
+	NoUser:           "no user has been set on this provider, are you sure the client has gone through the login process",
+	OAuth2Nil:        "no oauth2 credentials are set",
+	ReadResponse:     "could not read response: %v",
+	Response:         "not the expected response: %v",
+	SignOut:          "signing out failed: %v",
+	TokenError:       "token request failed %v: %v",
+}
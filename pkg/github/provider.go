@@ -0,0 +1,453 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kohirens/sso"
+)
+
+type Provider struct {
+	sso.Login
+	// APIURL Where the REST API lives, change this for GitHub Enterprise
+	// Server.
+	APIURL                string `json:"api_url"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	// Email The verified primary email address of the client.
+	Email string `json:"email"`
+	// OAuth2 The credentials and RedirectURI of the OAuth app registered
+	// with GitHub for this application.
+	OAuth2        *OAuth2
+	Scopes        []string `json:"scopes"`
+	State         string   `json:"state"`
+	Token         *Token   `json:"credentials"`
+	TokenEndpoint string   `json:"token_endpoint"`
+	// User The profile of the client, looked up after the code exchange.
+	User    *User `json:"user"`
+	client  sso.HttpClient
+	Prefix  string
+	session sso.SessionManager
+}
+
+var _ sso.OIDCProvider = (*Provider)(nil)
+
+// Application The client ID of the OAuth app registered with GitHub.
+func (p *Provider) Application() string {
+	return p.OAuth2.ClientID
+}
+
+// Authenticated Indicates if the client has been successfully authenticated
+// by GitHub.
+func (p *Provider) Authenticated() bool {
+	return p.Token != nil && !p.Token.Expired()
+}
+
+// AuthLink Generate a link to authenticate with the provider.
+func (p *Provider) AuthLink(loginHint string) (string, error) {
+	if p.AuthorizationEndpoint == "" {
+		return "", fmt.Errorf("%v", stderr.NoAuthEndpoint)
+	}
+
+	if e := sso.SaveState(p.session, p.sessionKey(sso.SessionState), p.State); e != nil {
+		return "", e
+	}
+
+	uri := fmt.Sprintf(
+		"%v?scope=%v&redirect_uri=%v&client_id=%v&state=%v",
+		p.AuthorizationEndpoint,
+		url.QueryEscape(strings.Join(p.Scopes, " ")),
+		url.QueryEscape(p.OAuth2.RedirectURI),
+		url.QueryEscape(p.OAuth2.ClientID),
+		p.State,
+	)
+
+	// GitHub takes a username rather than an email as the hint.
+	if loginHint != "" {
+		uri = uri + "&login=" + url.QueryEscape(loginHint)
+	}
+
+	Log.Dbugf("GitHub OAuth URI: %s", uri)
+
+	return uri, nil
+}
+
+// ClientEmail Return the verified primary email address of the client.
+func (p *Provider) ClientEmail() string {
+	email, e1 := p.ParseClientEmail()
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+	}
+
+	return email
+}
+
+// ClientID The numeric ID of the GitHub account, which unlike the login
+// (username) never changes.
+func (p *Provider) ClientID() string {
+	clientID, e1 := p.ParseClientID()
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+	}
+
+	return clientID
+}
+
+// ExchangeCodeForToken Trade the authorization code for an access token, then
+// use it to look up the user and their verified primary email address.
+func (p *Provider) ExchangeCodeForToken(state, code string) error {
+	if e := p.VerifyState(state); e != nil {
+		return e
+	}
+
+	reqBody := fmt.Sprintf(
+		"code=%v&redirect_uri=%v",
+		url.QueryEscape(code),
+		url.QueryEscape(p.OAuth2.RedirectURI),
+	)
+
+	token, e1 := p.requestToken("authorization_code", reqBody)
+	if e1 != nil {
+		return e1
+	}
+
+	p.Token = token
+
+	if e := p.LoadUser(); e != nil {
+		return e
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookLogin, id, p.LoginInfo(), nil); e != nil {
+		p.Token = nil
+		p.User = nil
+		p.Email = ""
+		return e
+	}
+
+	return nil
+}
+
+// LoadLoginInfo retrieve previous login info from storage.
+//
+//	NOTE: This requires the client to have consented beforehand. The
+//	best time to call this method is during or right after the callback.
+func (p *Provider) LoadLoginInfo(deviceID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return p.Open(id, deviceID, sessionID, userAgent)
+}
+
+// LoadUser Look up the user and their verified primary email address with
+// the access token.
+func (p *Provider) LoadUser() error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	user := &User{}
+	if e := p.get("/user", user); e != nil {
+		return e
+	}
+
+	var emails []*Email
+	if e := p.get("/user/emails", &emails); e != nil {
+		return e
+	}
+
+	email, e1 := PrimaryEmail(emails)
+	if e1 != nil {
+		return e1
+	}
+
+	p.User = user
+	p.Email = email
+
+	return nil
+}
+
+// Name ID of the provider.
+func (p *Provider) Name() string {
+	return "github"
+}
+
+// ParseClientEmail Return the verified primary email address of the client,
+// or an error when the user has not been looked up.
+func (p *Provider) ParseClientEmail() (string, error) {
+	if p.Email == "" {
+		return "", fmt.Errorf("%v", stderr.NoUser)
+	}
+
+	return p.Email, nil
+}
+
+// ParseClientID Return the numeric ID of the GitHub account, or an error
+// when the user has not been looked up.
+func (p *Provider) ParseClientID() (string, error) {
+	if p.User == nil {
+		return "", fmt.Errorf("%v", stderr.NoUser)
+	}
+
+	return strconv.FormatInt(p.User.ID, 10), nil
+}
+
+// RefreshToken Get a new access token from GitHub. OAuth app tokens do not
+// expire, so there is nothing to do unless token expiration is enabled for
+// the app.
+func (p *Provider) RefreshToken() error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	if p.Token.RefreshToken == "" {
+		return nil
+	}
+
+	reqBody := fmt.Sprintf(
+		"refresh_token=%v&grant_type=refresh_token",
+		url.QueryEscape(p.Token.RefreshToken),
+	)
+
+	token, e1 := p.requestToken("refresh_token", reqBody)
+	if e1 != nil {
+		return e1
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookRefresh, id, p.LoginInfo(), nil); e != nil {
+		return e
+	}
+
+	p.Token = token
+
+	return nil
+}
+
+// RegisterLoginInfo Register new login information.
+//
+//	NOTE: This is the only time the user agent is set on a device.
+func (p *Provider) RegisterLoginInfo(accountID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	// Token and user must be set.
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return nil, e
+	}
+
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return p.Register(id, accountID, sessionID, userAgent)
+}
+
+// RevokeDevice Sign the device out and stop trusting it, so that it must
+// authenticate again, then save the login info.
+func (p *Provider) RevokeDevice(deviceID string) error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Revoke(id, deviceID)
+}
+
+// SaveLoginInfo Save info for retrieval without hitting GitHub servers.
+func (p *Provider) SaveLoginInfo() error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Save(id)
+}
+
+// SignOut Revoke the access token, so the user must authorize the app again
+// to sign in, see:
+// https://docs.github.com/en/rest/apps/oauth-applications#delete-an-app-token
+// Will also remove any data stored in the session, and unbind the session
+// from the device.
+func (p *Provider) SignOut() error {
+	var revoke func() error
+	if p.Token != nil {
+		revoke = p.revokeToken
+	}
+
+	id, _ := p.identity(p.Token)
+	err := p.Login.SignOut(id, revoke)
+
+	if e := sso.ClearSession(p.session, p.sessionKey); e != nil {
+		Log.Warnf(stderr.SignOut, e.Error())
+	}
+
+	p.Token = nil
+
+	return err
+}
+
+// UpdateLoginInfo Address changes in the users login information, list the
+// devices, last activity time, etc.
+//
+//	NOTE: Never update the provider ClientID nor the user agent on the device,
+//	these are only set on registration.
+func (p *Provider) UpdateLoginInfo(deviceID, sessionID, userAgent string) error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return e
+	}
+
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Update(id, deviceID, sessionID, userAgent)
+}
+
+// UserInfo Get the profile of the client from the GitHub user looked up
+// after the code exchange. GitHub only has a display name, so the given and
+// family names are left empty.
+func (p *Provider) UserInfo() (*sso.Profile, error) {
+	if p.User == nil {
+		return nil, fmt.Errorf("%v", stderr.NoUser)
+	}
+
+	return &sso.Profile{
+		Subject:       p.ClientID(),
+		Name:          p.User.Name,
+		Picture:       p.User.AvatarURL,
+		Email:         p.Email,
+		EmailVerified: p.Email != "",
+	}, nil
+}
+
+// VerifyState Verify the state returned from the request matches the
+// original value sent.
+func (p *Provider) VerifyState(returnedState string) error {
+	return sso.VerifyState(p.session, p.sessionKey(sso.SessionState), returnedState)
+}
+
+// get Call the REST API with the access token and decode the response.
+func (p *Provider) get(path string, v interface{}) error {
+	headers := http.Header{}
+	headers.Add("Accept", "application/vnd.github+json")
+	headers.Add("Authorization", "Bearer "+p.Token.AccessToken)
+
+	res, e1 := sso.SendWithRetry(p.client, "GET", p.APIURL+path, nil, headers, http.StatusOK, 3)
+	if res == nil {
+		return fmt.Errorf(stderr.Response, e1)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	resBody, e2 := io.ReadAll(res.Body)
+	if e2 != nil {
+		return fmt.Errorf(stderr.ReadResponse, e2.Error())
+	}
+
+	if e := json.Unmarshal(resBody, v); e != nil {
+		return fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return nil
+}
+
+// identity Who the user looked up after the code exchange is, GitHub has no
+// ID token, so there are no claims, with an error when the user has not been
+// looked up.
+func (p *Provider) identity(token *Token) (*sso.Identity, error) {
+	id := &sso.Identity{Email: p.Email, Provider: p.Name()}
+	if token != nil {
+		id.RefreshToken = token.RefreshToken
+	}
+
+	if p.User == nil {
+		return id, fmt.Errorf("%v", stderr.NoUser)
+	}
+
+	id.Subject = strconv.FormatInt(p.User.ID, 10)
+	id.Location = p.location("logins/" + id.Subject)
+
+	return id, nil
+}
+
+// location Return the storage location.
+func (p *Provider) location(filename string) string {
+	return sso.Location(p.Prefix, filename)
+}
+
+// requestToken Post to the token endpoint, asking for a JSON response, and
+// time the request under the grant.
+func (p *Provider) requestToken(grant, reqBody string) (*Token, error) {
+	if p.TokenEndpoint == "" {
+		return nil, fmt.Errorf("%v", stderr.NoTokenEndpoint)
+	}
+
+	if p.OAuth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	reqBody = fmt.Sprintf(
+		"client_id=%v&client_secret=%v&%v",
+		url.QueryEscape(p.OAuth2.ClientID),
+		url.QueryEscape(p.OAuth2.ClientSecret),
+		reqBody,
+	)
+
+	headers := http.Header{}
+	headers.Add("Accept", "application/json")
+	headers.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	start := time.Now()
+	res, e1 := sso.SendWithRetry(p.client, "POST", p.TokenEndpoint, []byte(reqBody), headers, http.StatusOK, 3)
+	sso.ObserveTokenRequest(p.Name(), grant, start, res)
+	if res == nil {
+		return nil, fmt.Errorf(stderr.Response, e1)
+	}
+
+	return loadToken(res.Body)
+}
+
+// revokeToken Delete the access token with the GitHub REST API.
+func (p *Provider) revokeToken() error {
+	uri := fmt.Sprintf("%v/applications/%v/token", p.APIURL, url.PathEscape(p.OAuth2.ClientID))
+	reqBody := fmt.Sprintf(`{"access_token":%q}`, p.Token.AccessToken)
+
+	req, e1 := http.NewRequest("DELETE", uri, strings.NewReader(reqBody))
+	if e1 != nil {
+		return e1
+	}
+
+	req.SetBasicAuth(p.OAuth2.ClientID, p.OAuth2.ClientSecret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	res, e2 := p.client.Do(req)
+	if e2 != nil {
+		return e2
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf(stderr.Response, res.Status)
+	}
+
+	return nil
+}
+
+// sessionKey Where a value is kept in the session for this provider.
+func (p *Provider) sessionKey(name string) string {
+	return sso.SessionTokenGitHub + name
+}
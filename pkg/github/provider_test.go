@@ -0,0 +1,272 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kohirens/sso"
+	"github.com/kohirens/sso/internal/ssotest"
+	"github.com/kohirens/stdlib/test"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	tmpDir   = "tmp"
+	fixState = "abcdefghijklmnopqrstuvwxyz1234"
+)
+
+func TestMain(m *testing.M) {
+	test.ResetDir(tmpDir, 0777)
+
+	os.Exit(m.Run())
+}
+
+func fixtureProvider(client *test.MockHttpClient, store storage.Storage) *Provider {
+	return &Provider{
+		Login:                 sso.Login{Store: store},
+		APIURL:                "https://api.github.test",
+		AuthorizationEndpoint: "https://github.test/login/oauth/authorize",
+		OAuth2:                &OAuth2{ClientID: "c1", ClientSecret: "s1", RedirectURI: "https://example.com/callback"},
+		Scopes:                []string{"read:user", "user:email"},
+		State:                 fixState,
+		TokenEndpoint:         "https://github.test/login/oauth/access_token",
+		client:                client,
+		session:               ssotest.Session{},
+	}
+}
+
+func TestProvider_ExchangeCodeForToken(t *testing.T) {
+	goodToken := `{"access_token":"gho_1234","scope":"read:user,user:email","token_type":"bearer"}`
+	goodUser := `{"id":583231,"login":"octocat","name":"The Octocat"}`
+
+	tests := []struct {
+		name      string
+		bodies    map[string]string
+		wantID    string
+		wantEmail string
+		wantErr   bool
+	}{
+		{
+			"bad_verification_code",
+			map[string]string{
+				"/login/oauth/access_token": `{"error":"bad_verification_code","error_description":"The code passed is incorrect or expired."}`,
+			},
+			"",
+			"",
+			true,
+		},
+		{
+			"primary_email_not_verified",
+			map[string]string{
+				"/login/oauth/access_token": goodToken,
+				"/user":                     goodUser,
+				"/user/emails":              `[{"email":"octocat@github.com","primary":true,"verified":false}]`,
+			},
+			"",
+			"",
+			true,
+		},
+		{
+			"good",
+			map[string]string{
+				"/login/oauth/access_token": goodToken,
+				"/user":                     goodUser,
+				"/user/emails":              `[{"email":"other@example.com","primary":false,"verified":true},{"email":"octocat@github.com","primary":true,"verified":true}]`,
+			},
+			"583231",
+			"octocat@github.com",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := fixtureProvider(ssotest.Client(tt.bodies, nil), nil)
+			_, _ = p.AuthLink("")
+
+			err := p.ExchangeCodeForToken(fixState, "code1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExchangeCodeForToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if p.ClientID() != tt.wantID || p.ClientEmail() != tt.wantEmail {
+				t.Errorf("ExchangeCodeForToken() got %v %v, want %v %v", p.ClientID(), p.ClientEmail(), tt.wantID, tt.wantEmail)
+			}
+		})
+	}
+}
+
+type vetoHooks struct {
+	sso.NopHooks
+}
+
+func (vetoHooks) OnLogin(event *sso.HookEvent) error {
+	return fmt.Errorf("no logins for %v", event.Provider)
+}
+
+func TestProvider_ExchangeCodeForToken_Vetoed(t *testing.T) {
+	p := fixtureProvider(ssotest.Client(map[string]string{
+		"/login/oauth/access_token": `{"access_token":"gho_1234","scope":"read:user,user:email","token_type":"bearer"}`,
+		"/user":                     `{"id":583231,"login":"octocat","name":"The Octocat"}`,
+		"/user/emails":              `[{"email":"octocat@github.com","primary":true,"verified":true}]`,
+	}, nil), nil)
+	p.Hooks = vetoHooks{}
+	_, _ = p.AuthLink("")
+
+	var vetoed *sso.ErrVetoed
+	if e := p.ExchangeCodeForToken(fixState, "code1"); !errors.As(e, &vetoed) {
+		t.Errorf("ExchangeCodeForToken() error = %v, want ErrVetoed", e)
+		return
+	}
+
+	if p.Token != nil || p.User != nil || p.Email != "" {
+		t.Errorf("ExchangeCodeForToken() kept the client after the veto")
+	}
+}
+
+func TestProvider_LoginInfo(t *testing.T) {
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmpDir)
+
+	p := fixtureProvider(nil, store)
+	p.User = &User{ID: 583231, Login: "octocat"}
+	p.Email = "octocat@github.com"
+
+	var noToken *ErrNoToken
+	if _, e := p.RegisterLoginInfo("a1", "4321", ""); !errors.As(e, &noToken) {
+		t.Errorf("RegisterLoginInfo() error = %v, want ErrNoToken", e)
+		return
+	}
+
+	p.Token = &Token{AccessToken: "gho_1234"}
+
+	if _, e := p.RegisterLoginInfo("a1", "4321", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"); e != nil {
+		t.Errorf("RegisterLoginInfo() error = %v", e)
+		return
+	}
+
+	if !store.Exist("logins/583231.json") {
+		t.Errorf("RegisterLoginInfo() did not save to logins/583231.json")
+		return
+	}
+
+	li, e2 := p.LoadLoginInfo(p.DeviceID(), "4321", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+	if e2 != nil || li.AccountID != "a1" || li.Email != "octocat@github.com" {
+		t.Errorf("LoadLoginInfo() error = %v", e2)
+	}
+}
+
+func TestProvider_SignOut(t *testing.T) {
+	p := fixtureProvider(ssotest.Client(map[string]string{"/applications/c1/token": ""}, nil), nil)
+	p.Token = &Token{AccessToken: "gho_1234"}
+
+	if e := p.SignOut(); e != nil || p.Token != nil {
+		t.Errorf("SignOut() error = %v", e)
+	}
+}
+
+func TestProvider_VerifyState(t *testing.T) {
+	tests := []struct {
+		name    string
+		expiry  time.Duration
+		replay  bool
+		wantErr bool
+	}{
+		{"good", sso.StateExpiry, false, false},
+		{"replayed", sso.StateExpiry, true, true},
+		{"expired", -time.Minute, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(d time.Duration) { sso.StateExpiry = d }(sso.StateExpiry)
+			sso.StateExpiry = tt.expiry
+
+			// The callback is handled by another instance sharing the session.
+			p1 := fixtureProvider(nil, nil)
+			_, _ = p1.AuthLink("")
+			p2 := fixtureProvider(nil, nil)
+			p2.State = ""
+			p2.session = p1.session
+
+			if tt.replay {
+				_ = p2.VerifyState(fixState)
+			}
+
+			if err := p2.VerifyState(fixState); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_AuthLink_SignedState(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	state, _ := sso.NewSignedState(key, "/account/settings")
+
+	p1 := fixtureProvider(nil, nil)
+	p1.State = state
+	_, _ = p1.AuthLink("")
+
+	// The callback is handled by another instance sharing the session.
+	p2 := fixtureProvider(nil, nil)
+	p2.State = ""
+	p2.session = p1.session
+
+	if e := p2.VerifyState(state); e != nil {
+		t.Errorf("VerifyState() error = %v", e)
+		return
+	}
+
+	got, e := sso.ParseSignedState(key, state, &sso.ReturnURLs{Paths: []string{"/account"}})
+	if e != nil || got != "/account/settings" {
+		t.Errorf("ParseSignedState() = %v, error = %v", got, e)
+	}
+}
+
+// closeCounter A response body that counts when it is closed.
+type closeCounter struct {
+	io.Reader
+	closed *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestProvider_ExchangeCodeForToken_ClosesBodies(t *testing.T) {
+	var opened, closed int
+	client := ssotest.Client(map[string]string{
+		"/login/oauth/access_token": `{"access_token":"gho_1234","scope":"read:user,user:email","token_type":"bearer"}`,
+		"/user":                     `{"id":583231,"login":"octocat","name":"The Octocat"}`,
+		"/user/emails":              `[{"email":"octocat@github.com","primary":true,"verified":true}]`,
+	}, nil)
+	do := client.DoHandler
+	client.DoHandler = func(r *http.Request) (*http.Response, error) {
+		res, e := do(r)
+		opened++
+		res.Body = closeCounter{res.Body, &closed}
+		return res, e
+	}
+
+	p := fixtureProvider(client, nil)
+	_, _ = p.AuthLink("")
+
+	if e := p.ExchangeCodeForToken(fixState, "code1"); e != nil {
+		t.Errorf("ExchangeCodeForToken() error = %v", e)
+		return
+	}
+
+	if opened != 3 || closed != opened {
+		t.Errorf("ExchangeCodeForToken() closed %v of %v response bodies", closed, opened)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/kohirens/json-web-token"
 	"github.com/kohirens/sso"
 	"io"
-	"net/url"
 	"time"
 )
 
@@ -24,9 +23,28 @@ type Token struct {
 	TokenType    string `json:"token_type"`              // TokenType Identifies the type of token returned. At this time, this field always has the value Bearer.
 	RefreshToken string `json:"refresh_token,omitempty"` // RefreshToken (optional) This field is only present if the access_type parameter was set to offline in the authentication request. For details, see Refresh tokens.
 	info         *jwt.Info
+	claims       *sso.IDTokenClaims
 	Exp          *time.Time
 }
 
+// Claims Decode the claims of the ID token, this is only done once.
+func (t *Token) Claims() (*sso.IDTokenClaims, error) {
+	if t.claims == nil {
+		info, e1 := t.IDTokenInfo()
+		if e1 != nil {
+			return nil, e1
+		}
+
+		claims, e2 := sso.LoadIDTokenClaims(info.Payload)
+		if e2 != nil {
+			return nil, e2
+		}
+		t.claims = claims
+	}
+
+	return t.claims, nil
+}
+
 func (t *Token) Expired() bool {
 	return t.Exp != nil && t.Exp.Before(time.Now().UTC())
 }
@@ -59,16 +77,6 @@ func (t *Token) Validate() bool {
 	return t.Expired()
 }
 
-// NewStateWith Generates an anti-forgery unique session token, along with the
-// URI needed to recover the context when the user returns to your application
-// Read more at state:
-// https://developers.google.com/identity/openid-connect/openid-connect#state-param
-func NewStateWith(uri string) string {
-	state := fmt.Sprintf("security_token=%vurl=%v", sso.NewState(), uri)
-
-	return url.QueryEscape(state)
-}
-
 // loadToken Convert token data to a Token.
 func loadToken(rc io.ReadCloser) (*Token, error) {
 	resBody, e2 := io.ReadAll(rc)
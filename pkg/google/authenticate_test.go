@@ -29,28 +29,3 @@ func TestNewState(t *testing.T) {
 		})
 	}
 }
-
-// TestNewStateWith Check that unique session tokens meet expectations
-// Samples:
-// security_token%3D90bd70cc-1bd7-4ccc-818a-7aeae9414195url%3Dhttps%3A%2F%2Fexample.com%2Foauth-2-callback
-func TestNewStateWith(t *testing.T) {
-	cases := []struct {
-		name string
-		uri  string
-	}{
-		{"tokenonly", ""},
-		{"tokenonly", "https://example.com/oauth-2-callback"},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			got := NewStateWith(c.uri)
-			re := regexp.MustCompile(`^[a-zA-Z0-9-_%.]{36,}$`)
-
-			if !re.MatchString(got) {
-				t.Errorf("NewState() = %v, is not a valid unique session token", got)
-				return
-			}
-		})
-	}
-}
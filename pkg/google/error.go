@@ -1,46 +1,20 @@
 package google
 
-import "fmt"
+import (
+	"fmt"
 
-type ErrDeviceNotFound struct {
-	DeviceID string
-}
-
-func (e *ErrDeviceNotFound) Error() string {
-	return fmt.Sprintf(stderr.DeviceNotFound, e.DeviceID)
-}
-
-type ErrInvalidState struct {
-	msg      string
-	Location string
-	Code     int
-}
-
-func (e *ErrInvalidState) Error() string {
-	return e.msg
-}
+	"github.com/kohirens/sso"
+)
 
-type ErrNoLoginInfo struct {
-	DeviceID string
-}
+type ErrDeviceNotFound = sso.ErrDeviceNotFound
 
-func (e *ErrNoLoginInfo) Error() string {
-	return fmt.Sprintf(stderr.NoLoginInfo, e.DeviceID)
-}
+type ErrInvalidState = sso.ErrInvalidState
 
-type ErrNoSession struct{}
+type ErrNoLoginInfo = sso.ErrNoLoginInfo
 
-func (e *ErrNoSession) Error() string {
-	return "session manager is nil"
-}
+type ErrNoSession = sso.ErrNoSession
 
-type ErrNoSessionData struct {
-	data string
-}
-
-func (e *ErrNoSessionData) Error() string {
-	return fmt.Sprintf("no session data for %v", e.data)
-}
+type ErrNoSessionData = sso.ErrNoSessionData
 
 type ErrNoToken struct {
 	data string
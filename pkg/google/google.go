@@ -2,9 +2,9 @@ package google
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 
+	"github.com/kohirens/sso"
 	"github.com/kohirens/stdlib/logger"
 	"github.com/kohirens/www/storage"
 )
@@ -21,9 +21,7 @@ const (
 )
 
 // HttpClient Methods needed to make HTTP request.
-type HttpClient interface {
-	Do(req *http.Request) (*http.Response, error)
-}
+type HttpClient = sso.HttpClient
 
 // Session An session manager to be compatible with this library.
 type Session interface {
@@ -86,14 +84,14 @@ func NewProvider(client HttpClient, store storage.Storage, session Session, pref
 	}
 
 	gp := &Provider{
+		Login:        sso.Login{Store: store},
 		DiscoveryDoc: &DiscoverDoc{},
 		ProjectID:    projectID,
 		OAuth2:       oauth2,
 		Scopes:       []string{"openid", "profile", "email"},
-		State:        NewStateWith(oauth2.RedirectURI),
+		State:        sso.NewState(),
 		client:       client,
 		session:      session,
-		store:        store,
 		Prefix:       prefix,
 	}
 
@@ -74,3 +74,40 @@ func TestClientID(t *testing.T) {
 		})
 	}
 }
+
+func TestProvider_ParseClientID(t *testing.T) {
+	fixTokenID, _ := jwt.Token(jwt.ClaimSet{"alg": "HS256"}, jwt.ClaimSet{"sub": "10769150350006150715113082367", "email": "test@example.com"}, []byte("none"))
+	noSubTokenID, _ := jwt.Token(jwt.ClaimSet{"alg": "HS256"}, jwt.ClaimSet{"email": "test@example.com"}, []byte("none"))
+
+	cases := []struct {
+		name      string
+		token     *Token
+		want      string
+		wantEmail string
+		wantErr   bool
+	}{
+		{"no_token", nil, "", "", true},
+		{"malformed_token", &Token{IDToken: "not.a-jwt"}, "", "", true},
+		{"no_sub", &Token{IDToken: noSubTokenID}, "", "test@example.com", true},
+		{"good", &Token{IDToken: fixTokenID}, "10769150350006150715113082367", "test@example.com", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := &Provider{Token: c.token}
+
+			got, err := p.ParseClientID()
+			if (err != nil) != c.wantErr {
+				t.Errorf("ParseClientID() error = %v, wantErr %v", err, c.wantErr)
+				return
+			}
+
+			if got != c.want || p.ClientID() != c.want {
+				t.Errorf("ParseClientID() = %v, want %v", got, c.want)
+			}
+
+			if p.ClientEmail() != c.wantEmail {
+				t.Errorf("ClientEmail() = %v, want %v", p.ClientEmail(), c.wantEmail)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package google
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,15 +11,12 @@ import (
 	"strings"
 	"time"
 
-	jwt "github.com/kohirens/json-web-token"
 	"github.com/kohirens/sso"
-	"github.com/kohirens/www/storage"
-	"github.com/mileusna/useragent"
 )
 
 type Provider struct {
-	Code     string `json:"code"`
-	deviceID string
+	sso.Login
+	Code string `json:"code"`
 	// DiscoveryDoc contains well known info about the OIDC G discoveryDocument
 	DiscoveryDoc *DiscoverDoc `json:"discoveryDocument"`
 	// Hd To optimize the OpenID Connect flow for users of a particular domain
@@ -30,17 +27,18 @@ type Provider struct {
 	// environment this application runs in.
 	JWKs   *JwksUriv3 `json:"keys"`
 	OAuth2 *OAuth2
+	// PKCE Whether to use a code challenge, by default it is used since
+	// Google advertises the S256 method in the discovery document.
+	PKCE sso.PKCEMode `json:"pkce"`
 	// ProjectID name of the made in Google Cloud app.
 	ProjectID string   `json:"application"`
 	Scopes    []string `json:"scopes"`
 	State     string   `json:"state"`
 	// Credentials Clients login username and password.
-	Token     *Token `json:"credentials"`
-	client    HttpClient
-	Prefix    string
-	session   Session
-	store     storage.Storage
-	loginInfo *sso.LoginInfo
+	Token   *Token `json:"credentials"`
+	client  HttpClient
+	Prefix  string
+	session Session
 }
 
 // Application Name of the project made in Google Cloud app.
@@ -66,6 +64,15 @@ func (p *Provider) AuthLink(loginHint string) (string, error) {
 	// NOTE: Set the access_type parameter to offline so that a refresh token
 	// is returned with the ID token, see:
 	// https://developers.google.com/identity/openid-connect/openid-connect#exchangecode
+	if e := sso.SaveState(p.session, p.sessionKey(sso.SessionState), p.State); e != nil {
+		return "", e
+	}
+
+	nonce, e1 := sso.SaveNonce(p.session, p.sessionKey(sso.SessionNonce))
+	if e1 != nil {
+		return "", e1
+	}
+
 	uri := fmt.Sprintf(
 		"%v?response_type=code&scope=%v&redirect_uri=%v&client_id=%v&state=%v&nonce=%v&access_type=offline&prompt=consent",
 		epAuthentication,
@@ -73,7 +80,7 @@ func (p *Provider) AuthLink(loginHint string) (string, error) {
 		url.QueryEscape(p.OAuth2.RedirectURI),
 		p.OAuth2.ClientID,
 		p.State,
-		sso.NewNonce(),
+		nonce,
 	)
 
 	if loginHint != "" {
@@ -84,6 +91,12 @@ func (p *Provider) AuthLink(loginHint string) (string, error) {
 		uri = uri + "&hd=" + p.Hd
 	}
 
+	pkce, e2 := sso.PKCEChallenge(p.PKCE, p.DiscoveryDoc, p.session, p.sessionKey(sso.SessionCodeVerifier))
+	if e2 != nil {
+		return "", e2
+	}
+	uri = uri + pkce
+
 	Log.Dbugf("Google OIDC Auth URI: %s", uri)
 
 	return uri, nil
@@ -91,30 +104,13 @@ func (p *Provider) AuthLink(loginHint string) (string, error) {
 
 // Certificate JWK Download the certificates for validating ID tokens from Google.
 func (p *Provider) Certificate() error {
-	uri := p.DiscoveryDoc.JwksUri
-	if uri == "" {
-		return fmt.Errorf(stderr.MissEnvVar, p.DiscoveryDoc.JwksUri)
-	}
-
-	Log.Infof(stdout.Url, uri)
-
-	res, e1 := SendWithRetry(p.client, "GET", uri, nil, nil, 200, 3)
-	if e1 != nil {
-		return fmt.Errorf(stderr.Response, e1.Error())
-	}
-
-	resBody, e2 := io.ReadAll(res.Body)
-	if e2 != nil {
-		return fmt.Errorf(stderr.ReadResponse, e2.Error())
-	}
-
-	var err error
-	p.JWKs, err = LoadJwksUriv3(resBody)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return sso.RefreshDocument(
+		p.client,
+		p.Store,
+		p.location(keyCertificate),
+		p.DiscoveryDoc.JwksUri,
+		p.setCertificate,
+	)
 }
 
 // ClientID An ID unique to a Google Account even if the user changes their
@@ -128,25 +124,12 @@ func (p *Provider) Certificate() error {
 //	For details see:
 //	https://developers.google.com/identity/openid-connect/openid-connect#obtainuserinfo
 func (p *Provider) ClientID() string {
-	// ClientID will be the users Google ID stores in a folder
-	// /login/<google-user-id>. The data has to be encrypted since it will
-	// contain PII. For compliance, it will be encrypted with the apps GPG key.
-	idToken, e1 := p.Token.IDTokenInfo()
+	clientID, e1 := p.ParseClientID()
 	if e1 != nil {
-		panic(e1)
-	}
-
-	sub, ok := idToken.Payload["sub"]
-	if !ok {
-		panic(fmt.Sprintf("%v", stderr.IDTokenNoSub))
+		Log.Errf("%v", e1.Error())
 	}
 
-	return sub.(string)
-}
-
-// DeviceID Get the ID of the device the user is currently logged in with.
-func (p *Provider) DeviceID() string {
-	return p.deviceID
+	return clientID
 }
 
 func (p *Provider) DiscoveryDocDownload() error {
@@ -155,119 +138,72 @@ func (p *Provider) DiscoveryDocDownload() error {
 		return fmt.Errorf(stderr.MissEnvVar, envDiscoverDocURL)
 	}
 
-	Log.Infof(stdout.Url, uri)
-
-	res, e1 := SendWithRetry(p.client, "GET", uri, nil, nil, 200, 3)
+	resBody, e1 := sso.Download(p.client, uri)
 	if e1 != nil {
-		return fmt.Errorf(stderr.Response, e1.Error())
+		return e1
 	}
 
-	resBody, e2 := io.ReadAll(res.Body)
-	if e2 != nil {
-		return fmt.Errorf(stderr.ReadResponse, e2.Error())
-	}
+	return p.DiscoverDoc(resBody)
+}
 
-	if e := json.Unmarshal(resBody, p.DiscoveryDoc); e != nil {
-		return fmt.Errorf(stderr.DecodeJSON, e.Error())
+// DiscoverDoc Decode the discovery document data and set it on the provider.
+func (p *Provider) DiscoverDoc(dd []byte) error {
+	doc, e1 := sso.LoadDiscoverDoc(dd)
+	if e1 != nil {
+		return e1
 	}
 
-	p.DiscoveryDoc.rawBytes = resBody
+	p.DiscoveryDoc = doc
 
 	return nil
 }
 
-// DiscoverDoc
-func (p *Provider) DiscoverDoc(dd []byte) error {
-	if e := json.Unmarshal(dd, p.DiscoveryDoc); e != nil {
-		return fmt.Errorf(stderr.DecodeJSON, e.Error())
+// IDTokenClaims Return the claims of the ID token.
+func (p *Provider) IDTokenClaims() (*sso.IDTokenClaims, error) {
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
 	}
 
-	p.DiscoveryDoc.rawBytes = dd
+	claims, e1 := p.Token.Claims()
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
 
-	return nil
+	return claims, nil
 }
 
 // LoadCertificate Load the Google public Certificate, try from cache first,
 // then download from the internet if that fails.
 func (p *Provider) LoadCertificate() error {
-	var e2 error
-	filename := p.location(keyCertificate)
-	dd, e1 := p.store.Load(filename)
-	if e1 != nil {
-		Log.Warnf("%v", e1.Error())
-		goto download
-	}
-
-	p.JWKs, e2 = LoadJwksUriv3(dd)
-	if e2 == nil {
-		return nil
-	}
-	Log.Warnf("%v", e2.Error())
-
-download:
-	Log.Errf("%v", stderr.CertificateCache)
-
-	// Download the Google Certificate
-	if e := p.Certificate(); e != nil {
-		return e
-	}
-
-	// Save to the storage device.
-	if e := p.store.Save(filename, p.JWKs.rawBytes); e != nil {
-		Log.Warnf("%v", e.Error())
-	}
-
-	Log.Infof("should have saved certificate to %v", keyCertificate)
-	return nil
+	return sso.LoadDocument(
+		p.client,
+		p.Store,
+		p.location(keyCertificate),
+		p.DiscoveryDoc.JwksUri,
+		p.setCertificate,
+	)
 }
 
 // LoadDiscoveryDoc Load the Google Discovery document, try from cache first,
 // then download from the internet if that fails.
 func (p *Provider) LoadDiscoveryDoc() error {
-	filename := p.location(keyDiscoveryDoc)
-	dd, e1 := p.store.Load(filename)
-	if e1 != nil {
-		Log.Warnf("%v", e1.Error())
-		goto download
-	}
-
-	{
-		e2 := p.DiscoverDoc(dd)
-		if e2 == nil {
-			return nil
-		}
-		Log.Errf(stderr.LoadDiscoveryDoc, e2.Error())
-	}
-
-download:
-
-	Log.Errf("%v", stderr.DiscoveryDocCache)
-	// Download the Google Discover Document
-	if e := p.DiscoveryDocDownload(); e != nil {
-		return e
-	}
-
-	if e := p.store.Save(filename, p.DiscoveryDoc.rawBytes); e != nil {
-		Log.Warnf("%v", e.Error())
-	}
-
-	//  to the storage device.
-	return nil
+	return sso.LoadDocument(
+		p.client,
+		p.Store,
+		p.location(keyDiscoveryDoc),
+		os.Getenv(envDiscoverDocURL),
+		p.DiscoverDoc,
+	)
 }
 
 // ClientEmail Return the logged in clients email address.
 func (p *Provider) ClientEmail() string {
-	idToken, e1 := p.Token.IDTokenInfo()
+	email, e1 := p.ParseClientEmail()
 	if e1 != nil {
-		panic(e1)
-	}
-
-	email, ok := idToken.Payload["email"]
-	if !ok {
-		panic(fmt.Sprintf("%v", stderr.IDTokenNoEmail))
+		Log.Errf("%v", e1.Error())
 	}
 
-	return email.(string)
+	return email
 }
 
 // ExchangeCodeForToken An authorization code obtained after the HttpClient
@@ -275,6 +211,7 @@ func (p *Provider) ClientEmail() string {
 // token obtained from Google.
 func (p *Provider) ExchangeCodeForToken(state, code string) error {
 	if e := p.VerifyState(state); e != nil {
+		p.audit(sso.AuditStateMismatch, e)
 		return e
 	}
 
@@ -289,18 +226,31 @@ func (p *Provider) ExchangeCodeForToken(state, code string) error {
 		return fmt.Errorf("%v", stderr.OAuth2Nil)
 	}
 
+	nonce, e4 := sso.LoadNonce(p.session, p.sessionKey(sso.SessionNonce))
+	if e4 != nil {
+		return e4
+	}
+
+	pkce, e2 := sso.PKCEVerifier(p.PKCE, p.DiscoveryDoc, p.session, p.sessionKey(sso.SessionCodeVerifier))
+	if e2 != nil {
+		return e2
+	}
+
 	reqBody := fmt.Sprintf(
-		"code=%v&client_id=%v&client_secret=%v&redirect_uri=%v&grant_type=authorization_code",
+		"code=%v&client_id=%v&client_secret=%v&redirect_uri=%v&grant_type=authorization_code%v",
 		code,
 		p.OAuth2.ClientID,
 		p.OAuth2.ClientSecret,
 		url.QueryEscape(p.OAuth2.RedirectURI),
+		pkce,
 	)
 
 	headers := http.Header{}
 	headers.Add("Content-Type", "application/x-www-form-urlencoded")
 
+	start := time.Now()
 	res, e1 := SendWithRetry(p.client, "POST", uri, []byte(reqBody), headers, http.StatusOK, 3)
+	sso.ObserveTokenRequest(p.Name(), "authorization_code", start, res)
 	if e1 != nil {
 		return e1
 	}
@@ -315,6 +265,24 @@ func (p *Provider) ExchangeCodeForToken(state, code string) error {
 	}
 
 	if e := p.ValidateToken(token); e != nil {
+		p.audit(sso.AuditTokenInvalid, e)
+		return e
+	}
+
+	// Verify the nonce claim matches the nonce sent with the login, so that
+	// an ID token cannot be replayed.
+	claims, e5 := token.Claims()
+	if e5 != nil {
+		return fmt.Errorf(stderr.ParsingIDToken, e5.Error())
+	}
+
+	if e := sso.VerifyNonce(nonce, claims.Nonce); e != nil {
+		p.audit(sso.AuditTokenInvalid, e)
+		return e
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookLogin, id, p.LoginInfo(), nil); e != nil {
 		return e
 	}
 
@@ -325,6 +293,51 @@ func (p *Provider) ExchangeCodeForToken(state, code string) error {
 	return nil
 }
 
+// FinishDeviceLogin Wait for the user to approve the device, with the codes
+// from StartDeviceLogin, then validate the ID token and load the login info
+// of the device, registering it on the first login.
+func (p *Provider) FinishDeviceLogin(ctx context.Context, da *sso.DeviceAuthorization, accountID, deviceID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	if p.OAuth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	reqBody := fmt.Sprintf(
+		"client_id=%v&client_secret=%v&device_code=%v&grant_type=%v",
+		url.QueryEscape(p.OAuth2.ClientID),
+		url.QueryEscape(p.OAuth2.ClientSecret),
+		url.QueryEscape(da.DeviceCode),
+		url.QueryEscape(sso.GrantTypeDeviceCode),
+	)
+
+	data, e1 := sso.PollDeviceToken(ctx, p.client, p.DiscoveryDoc.TokenEndpoint, reqBody, nil, da)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	token, e2 := loadToken(io.NopCloser(bytes.NewReader(data)))
+	if e2 != nil {
+		return nil, e2
+	}
+
+	if e := p.ValidateToken(token); e != nil {
+		p.audit(sso.AuditTokenInvalid, e)
+		return nil, e
+	}
+
+	id, e3 := p.identity(token)
+	if e3 != nil {
+		return nil, e3
+	}
+
+	if e := p.RunHook(sso.HookLogin, id, nil, nil); e != nil {
+		return nil, e
+	}
+
+	p.Token = token
+
+	return p.SignIn(id, accountID, deviceID, sessionID, userAgent)
+}
+
 func (p *Provider) HasTokenExpired(auth2 *OAuth2) bool {
 	// TODO Implement
 	return true
@@ -335,42 +348,48 @@ func (p *Provider) HasTokenExpired(auth2 *OAuth2) bool {
 //	NOTE: This requires the client to have consented beforehand. The
 //	best time to call this method is during or right after the callback.
 func (p *Provider) LoadLoginInfo(deviceID, sessionID, userAgent string) (*sso.LoginInfo, error) {
-	// Token must be set.
-	if p.Token == nil {
-		panic(stderr.NoToken)
+	// Token and ClientID MUST be set.
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
 	}
 
-	// ClientID MUST be set.
-	filename := p.loginFilename()
-	liData, e1 := p.store.Load(filename)
-	if e1 != nil { // When you cannot load it, then just make it.
-		return nil, &ErrNoLoginInfo{filename}
-	}
+	return p.Open(id, deviceID, sessionID, userAgent)
+}
+
+// Name ID of the OIDC application registered with the provider
+func (p *Provider) Name() string {
+	return "google"
+}
 
-	li := &sso.LoginInfo{}
-	if e := json.Unmarshal(liData, li); e != nil {
-		return nil, fmt.Errorf(stderr.EncodeJSON, e)
+// ParseClientEmail Return the logged in clients email address from the ID
+// token, or an error when there is no token, or it has no email.
+func (p *Provider) ParseClientEmail() (string, error) {
+	claims, e1 := p.IDTokenClaims()
+	if e1 != nil {
+		return "", e1
 	}
 
-	p.loginInfo = li
-	// look for the device
-	var err error
-	if deviceID != "" {
-		d, e := p.loginInfo.LookupDevice(deviceID, sessionID, userAgent)
-		if e != nil {
-			Log.Warnf("%v", e.Error())
-		}
-		if d != nil {
-			p.deviceID = d.ID
-		}
+	if claims.Email == "" {
+		return "", fmt.Errorf("%v", stderr.IDTokenNoEmail)
 	}
 
-	return p.loginInfo, err
+	return claims.Email, nil
 }
 
-// Name ID of the OIDC application registered with the provider
-func (p *Provider) Name() string {
-	return "google"
+// ParseClientID Return the sub claim of the ID token, or an error when there
+// is no token, or it has no sub.
+func (p *Provider) ParseClientID() (string, error) {
+	claims, e1 := p.IDTokenClaims()
+	if e1 != nil {
+		return "", e1
+	}
+
+	if claims.Subject == "" {
+		return "", fmt.Errorf("%v", stderr.IDTokenNoSub)
+	}
+
+	return claims.Subject, nil
 }
 
 // RefreshToken Get a new token from Google authentication servers.
@@ -389,7 +408,9 @@ func (p *Provider) RefreshToken() error {
 
 	headers := http.Header{}
 	headers.Add("Content-Type", "application/x-www-form-urlencoded")
+	start := time.Now()
 	res, e1 := SendWithRetry(p.client, "POST", uri, []byte(reqBody), headers, http.StatusOK, 3)
+	sso.ObserveTokenRequest(p.Name(), "refresh_token", start, res)
 	if e1 != nil {
 		return fmt.Errorf("%v", e1.Error())
 	}
@@ -400,10 +421,22 @@ func (p *Provider) RefreshToken() error {
 	}
 
 	if e := p.ValidateToken(token); e != nil {
+		p.audit(sso.AuditTokenInvalid, e)
+		return e
+	}
+
+	// Google does not send a new refresh token, so keep the one used.
+	if token.RefreshToken == "" {
+		token.RefreshToken = p.Token.RefreshToken
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookRefresh, id, p.LoginInfo(), nil); e != nil {
 		return e
 	}
 
 	p.Token = token
+	p.audit(sso.AuditRefresh, nil)
 
 	return nil
 }
@@ -414,52 +447,83 @@ func (p *Provider) RefreshToken() error {
 func (p *Provider) RegisterLoginInfo(accountID, sessionID, userAgent string) (*sso.LoginInfo, error) {
 	// Token must be set.
 	if p.Token == nil {
-		panic(stderr.NoToken)
+		return nil, &ErrNoToken{}
 	}
 
-	li := &sso.LoginInfo{
-		AccountID:    accountID,
-		Devices:      make(map[string]*sso.Device),
-		Email:        p.ClientEmail(),
-		ClientID:     p.ClientID(),
-		RefreshToken: p.Token.RefreshToken,
+	if _, e := p.ParseClientEmail(); e != nil {
+		return nil, e
 	}
 
-	device := sso.NewDevice(userAgent, sessionID, p.Name())
-	li.Devices[device.ID] = device
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
 
-	p.deviceID = device.ID
-	p.loginInfo = li
+	return p.Register(id, accountID, sessionID, userAgent)
+}
 
-	// register the login info
-	if e := p.SaveLoginInfo(); e != nil {
-		return nil, e
+// RevokeDevice Sign the device out and stop trusting it, so that it must
+// authenticate again, then save the login info.
+func (p *Provider) RevokeDevice(deviceID string) error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
 	}
 
-	return p.loginInfo, nil
+	return p.Revoke(id, deviceID)
 }
 
 // SaveLoginInfo Save info for retrieval without hitting Google servers.
 func (p *Provider) SaveLoginInfo() error {
-	liData, e1 := json.Marshal(p.loginInfo)
+	id, e1 := p.identity(p.Token)
 	if e1 != nil {
-		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+		return e1
 	}
 
-	return p.store.Save(p.loginFilename(), liData)
+	return p.Save(id)
 }
 
-// SignOut Should invalidate any token used to sign in.
-// Will also remove any data stored in the session,
+// SignOut Revoke the token with Google, so the user must consent again to
+// sign in to your application. Will also remove any data stored in the
+// session, and unbind the session from the device.
 func (p *Provider) SignOut() error {
-	// TODO: Implement
-	// so far I see no way to logout other than delete thesssion
-	// and maybe revoke the token.
-	return nil
+	var revoke func() error
+	if p.Token != nil {
+		revoke = p.revokeToken
+	}
+
+	id, _ := p.identity(p.Token)
+	err := p.Login.SignOut(id, revoke)
+
+	if e := sso.ClearSession(p.session, p.sessionKey); e != nil {
+		Log.Warnf(stderr.SignOut, e.Error())
+	}
+
+	p.Token = nil
+
+	return err
+}
+
+// StartDeviceLogin Begin a login on a device without a browser, such as a TV
+// or CLI. Show the user code and verification URI to the user, then call
+// FinishDeviceLogin.
+func (p *Provider) StartDeviceLogin() (*sso.DeviceAuthorization, error) {
+	if p.OAuth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	reqBody := fmt.Sprintf(
+		"client_id=%v&scope=%v",
+		url.QueryEscape(p.OAuth2.ClientID),
+		strings.Join(p.Scopes, "%20"),
+	)
+
+	return sso.StartDeviceAuthorization(p.client, p.DiscoveryDoc.DeviceAuthorizationEndpoint, reqBody, nil)
 }
 
 // UpdateLoginInfo Address changes in the users login information, list the
-// devices, last activity time, etc.
+// devices, last activity time, etc. The token is refreshed first when there
+// is a refresh token, so a token revoked with Google ends the login.
 //
 //	NOTE: Never update the provider ClientID nor the user agent on the device,
 //	these are only set on registration.
@@ -468,141 +532,134 @@ func (p *Provider) UpdateLoginInfo(deviceID, sessionID, userAgent string) error
 		return &ErrNoToken{}
 	}
 
-	if p.loginInfo == nil {
-		return &ErrNoLoginInfo{deviceID}
+	// Google only sends a refresh token on consent, without one there is
+	// nothing to refresh.
+	if p.Token.RefreshToken != "" {
+		if e := p.RefreshToken(); e != nil {
+			return e
+		}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return e
 	}
-	// Update login info.
-	p.loginInfo.RefreshToken = p.RefreshToken()
-	p.loginInfo.Email = p.ClientEmail()
 
-	device := p.loginInfo.Devices[deviceID]
-	if device == nil {
-		return &ErrDeviceNotFound{deviceID}
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
 	}
 
-	p.deviceID = device.ID
-	// Sessions are ephemeral, so we just replace them.
-	device.SessionID = sessionID
-	if device.UserAgent == nil {
-		ua := useragent.Parse(userAgent)
-		device.UserAgent = &ua
+	return p.Update(id, deviceID, sessionID, userAgent)
+}
+
+// UserInfo Get the profile of the client, such as their name and picture,
+// from the Google userinfo endpoint. Falls back to the claims in the ID token
+// when the endpoint cannot be reached.
+func (p *Provider) UserInfo() (*sso.Profile, error) {
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
 	}
-	device.LastActivity = time.Now()
 
-	// Store that token away for safe keeping
-	if e := p.SaveLoginInfo(); e != nil {
-		return e
+	info, e1 := p.Token.IDTokenInfo()
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
 	}
 
-	return nil
+	return sso.UserInfo(p.client, p.DiscoveryDoc.UserinfoEndpoint, p.Token.AccessToken, info.Payload)
 }
 
 // ValidateToken Validate an ID token came from Google.
 // https://developers.google.com/identity/openid-connect/openid-connect#validatinganidtoken
 func (p *Provider) ValidateToken(token *Token) error {
-	if token == nil {
-		return fmt.Errorf("%v", stderr.ValidateTokenNil)
-	}
+	reason, err := p.validateToken(token)
+	sso.CountValidation(p.Name(), reason)
 
-	// Convert the ID token string into code.
-	info, e1 := token.IDTokenInfo()
-	if e1 != nil {
-		return fmt.Errorf(stderr.ParsingIDToken, e1.Error())
-	}
+	return err
+}
 
-	if p.JWKs == nil {
-		return fmt.Errorf("%v", stderr.NoCerts)
-	}
+// VerifyState Verify the state returned from the request matches the
+// original value sent.
+func (p *Provider) VerifyState(returnedState string) error {
+	return sso.VerifyState(p.session, p.sessionKey(sso.SessionState), returnedState)
+}
 
-	// Get the RSA public keys which we have retrieved from Google.
-	keys, e2 := ParseRSAPublicKeys(p.JWKs.Keys)
-	if e2 != nil {
-		return fmt.Errorf(stderr.ValidateTokenKeys, e2.Error())
-	}
+// audit Record an event about the current device, when an auditor is set.
+func (p *Provider) audit(eventType sso.AuditEventType, reason error) {
+	id, _ := p.identity(p.Token)
+	p.Record(id, eventType, reason)
+}
 
-	// 1.Verify that the ID token is properly signed by the issuer.
-	valid := false
-	for _, key := range keys {
-		if e3 := jwt.ValidateSignatureRS256Pub([]byte(token.IDToken), key); e3 == nil {
-			valid = true
-		}
-	}
-	if !valid {
-		return fmt.Errorf("%v", stderr.SignatureVerify)
+// identity Who the token signed in, filled in as far as the token allows,
+// with an error when that is not enough to find their login info.
+func (p *Provider) identity(token *Token) (*sso.Identity, error) {
+	id := &sso.Identity{Provider: p.Name()}
+	if token == nil {
+		return id, &ErrNoToken{}
 	}
 
-	// 2. Verify that the value of the iss claim in the ID token is equal to https://accounts.google.com or accounts.google.com.
-	iss, ok1 := info.Payload["iss"]
-	if !ok1 || p.DiscoveryDoc.Issuer != iss {
-		return fmt.Errorf(stderr.ValidateTokenIss, iss)
-	}
+	id.RefreshToken = token.RefreshToken
 
-	// 3. Verify that the value of the aud claim in the ID token is equal to your app's client ID.
-	encAud, ok2 := info.Payload["aud"]
-	if !ok2 {
-		return fmt.Errorf(stderr.ValidateTokenAud, encAud, p.ProjectID)
-	}
-	aud, e4 := url.QueryUnescape(encAud.(string))
-	if e4 != nil {
-		return fmt.Errorf(stderr.AudDecode, e4.Error())
+	claims, e1 := token.Claims()
+	if e1 != nil {
+		return id, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
 	}
 
-	if aud != p.OAuth2.ClientID {
-		return fmt.Errorf(stderr.ValidateTokenPrj, encAud, p.OAuth2.ClientID)
-	}
+	id.Claims = claims
+	id.Email = claims.Email
+	id.Subject = claims.Subject
 
-	// 4. Verify that the expiry time (exp claim) of the ID token has not passed.
-	if token.Expired() {
-		return fmt.Errorf("%v", stderr.ValidateTokenExp)
+	if claims.Subject == "" {
+		return id, fmt.Errorf("%v", stderr.IDTokenNoSub)
 	}
 
-	// TODO: Test with an hd passed into the authorization URL.
-	// 5. If you specified a hd parameter value in the request, verify that the ID token has a hd claim that matches an accepted domain associated with a Google Cloud organization.
-	if p.Hd != "" {
-		hd, ok3 := info.Payload["hd"]
-		if !ok3 || hd != p.Hd {
-			return fmt.Errorf(stderr.ValidateTokenHd, hd, p.Hd)
-		}
-	}
+	id.Location = p.location("logins/" + claims.Subject)
 
-	return nil
+	return id, nil
 }
 
-// VerifyState Verify the state returned from the request matches the
-// original value sent.
-func (p *Provider) VerifyState(returnedSate string) error {
-	// Check either state is not empty
-	if len(returnedSate) < 30 || len(p.State) < 30 { // Log error and redirect to login page.
-		return &ErrInvalidState{stderr.InvalidState, "/?m=invalid-state", http.StatusSeeOther}
-	}
+// location Return the storage location.
+func (p *Provider) location(filename string) string {
+	return sso.Location(p.Prefix, filename)
+}
 
-	sState, e1 := url.QueryUnescape(p.State)
-	if e1 != nil {
-		Log.Errf(stderr.QueryUnescape, e1.Error())
+// refreshCertificate Download the JWKs again, for when the keys were rotated.
+func (p *Provider) refreshCertificate() (*sso.JwksUriv3, error) {
+	if e := p.Certificate(); e != nil {
+		return nil, e
 	}
 
-	Log.Dbugf("rtn-state: %v", returnedSate)
-	Log.Dbugf("org-state: %s", sState)
+	return p.JWKs, nil
+}
 
-	// Compare the state field from the URL and the session.
-	if returnedSate != sState { // Log error and redirect to login page.
-		return &ErrInvalidState{stderr.StateMismatch, "/?=bad-state", http.StatusSeeOther}
+// revokeToken Revoke the refresh token, or the access token when there is no
+// refresh token. Revoking the refresh token also revokes the access tokens
+// made from it.
+func (p *Provider) revokeToken() error {
+	token, hint := p.Token.RefreshToken, "refresh_token"
+	if token == "" {
+		token, hint = p.Token.AccessToken, "access_token"
 	}
 
-	return nil
+	reqBody := fmt.Sprintf("token=%v&token_type_hint=%v", url.QueryEscape(token), hint)
+
+	return sso.RevokeToken(p.client, p.DiscoveryDoc.RevocationEndpoint, reqBody, nil)
 }
 
-// location Return the storage location.
-func (p *Provider) location(filename string) string {
-	if p.Prefix != "" {
-		return p.Prefix + "/" + filename + ".json"
-	}
-	return filename + ".json"
+// sessionKey Where a value is kept in the session for this provider.
+func (p *Provider) sessionKey(name string) string {
+	return sso.SessionTokenGoogle + name
 }
 
-// loginFilename loginFile where to look for the file containing login information.
-func (p *Provider) loginFilename() string {
-	return p.location("logins/" + p.ClientID())
+// setCertificate Decode the JWKs used to validate ID tokens.
+func (p *Provider) setCertificate(data []byte) error {
+	jwks, e := LoadJwksUriv3(data)
+	if e != nil {
+		return e
+	}
+
+	p.JWKs = jwks
+
+	return nil
 }
 
 // SendWithRetry Make an HTTP request, retrying up to so many times.
@@ -621,49 +678,64 @@ func SendWithRetry(
 	code,
 	retries int,
 ) (*http.Response, error) {
-	body := bytes.NewBuffer(data)
+	return sso.SendWithRetry(httpClient, method, url, data, headers, code, retries)
+}
 
-	req, e1 := http.NewRequest(method, url, body)
-	if e1 != nil {
-		return nil, fmt.Errorf(stderr.BuildRequest, e1.Error())
+// validateToken Validate the ID token, returning why it failed for the
+// metrics.
+func (p *Provider) validateToken(token *Token) (string, error) {
+	if token == nil {
+		return sso.TokenReasonMalformed, fmt.Errorf("%v", stderr.ValidateTokenNil)
 	}
 
-	req.Header = headers
-	var lastResponse *http.Response
-	var errMessage string
-
-	for attempt := 1; attempt <= retries; attempt++ {
-		res, err := httpClient.Do(req)
-		if err != nil {
-			errMessage += fmt.Sprintf(stderr.RetryRequest, err.Error())
-			continue
-		}
+	// Convert the ID token string into code.
+	info, e1 := token.IDTokenInfo()
+	if e1 != nil {
+		return sso.TokenReasonMalformed, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
 
-		if res.StatusCode == code {
-			lastResponse = res
-			break
-		}
+	if p.JWKs == nil {
+		return sso.TokenReasonNoKeys, fmt.Errorf("%v", stderr.NoCerts)
+	}
 
-		// condition where response is not the expected status code but err is
-		// nil
-		// We throw this back at the app to let the dev know they should handle
-		// this particular case.
-		resBody, _ := io.ReadAll(res.Body)
-		_ = res.Body.Close()
+	// 1.Verify that the ID token is properly signed by the issuer, using the
+	// key named in the header of the ID token.
+	if e := sso.VerifySignature(token.IDToken, info.Header, p.JWKs, p.DiscoveryDoc.JwksUri, p.refreshCertificate); e != nil {
+		return sso.TokenReasonSignature, fmt.Errorf(stderr.SignatureVerify, e.Error())
+	}
 
-		errMessage += fmt.Sprintf(stderr.UnexpectedCode, attempt, url, res.StatusCode, string(resBody))
+	// 2. Verify that the value of the iss claim in the ID token is equal to https://accounts.google.com or accounts.google.com.
+	iss, ok1 := info.Payload["iss"]
+	if !ok1 || p.DiscoveryDoc.Issuer != iss {
+		return sso.TokenReasonIssuer, fmt.Errorf(stderr.ValidateTokenIss, iss)
+	}
 
-		res = nil
+	// 3. Verify that the value of the aud claim in the ID token is equal to your app's client ID.
+	// The aud claim may be a single string or an array of strings.
+	claims, e4 := token.Claims()
+	if e4 != nil {
+		return sso.TokenReasonMalformed, fmt.Errorf(stderr.ParsingIDToken, e4.Error())
+	}
+	if len(claims.Audience) == 0 {
+		return sso.TokenReasonAudience, fmt.Errorf("%v", stderr.ValidateTokenAud)
+	}
+	if !claims.Audience.Contains(p.OAuth2.ClientID) {
+		return sso.TokenReasonAudience, fmt.Errorf(stderr.ValidateTokenPrj, claims.Audience, p.OAuth2.ClientID)
 	}
 
-	var lastErr error
-	if errMessage != "" {
-		lastErr = fmt.Errorf("%v", errMessage)
+	// 4. Verify that the expiry time (exp claim) of the ID token has not passed.
+	if token.Expired() || time.Unix(claims.Expires, 0).Before(time.Now()) {
+		return sso.TokenReasonExpired, fmt.Errorf("%v", stderr.ValidateTokenExp)
 	}
 
-	if lastResponse == nil {
-		return nil, lastErr
+	// TODO: Test with an hd passed into the authorization URL.
+	// 5. If you specified a hd parameter value in the request, verify that the ID token has a hd claim that matches an accepted domain associated with a Google Cloud organization.
+	if p.Hd != "" {
+		hd, ok3 := info.Payload["hd"]
+		if !ok3 || hd != p.Hd {
+			return sso.TokenReasonHostedDomain, fmt.Errorf(stderr.ValidateTokenHd, hd, p.Hd)
+		}
 	}
 
-	return lastResponse, lastErr
+	return "", nil
 }
@@ -3,13 +3,18 @@ package google
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	jwt "github.com/kohirens/json-web-token"
 	"github.com/kohirens/sso"
+	"github.com/kohirens/sso/internal/ssotest"
 	"github.com/kohirens/stdlib/fsio"
 	"github.com/kohirens/stdlib/test"
 	"github.com/kohirens/www/storage"
@@ -154,13 +159,13 @@ func TestProvider_SaveLoginInfo(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			p := &Provider{
+				Login: sso.Login{Store: tt.Store},
 				Token: tt.Token,
-				store: tt.Store,
-				loginInfo: &sso.LoginInfo{
-					ClientID: "4321",
-					Devices:  make(map[string]*sso.Device),
-				},
 			}
+			p.SetLoginInfo(&sso.LoginInfo{
+				ClientID: "4321",
+				Devices:  make(map[string]*sso.Device),
+			})
 
 			if tt.makePrefix {
 				_ = os.MkdirAll(tmpDir+"/logins", 0777)
@@ -245,8 +250,8 @@ func TestProvider_LoadLoginInfo(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			p := &Provider{
+				Login: sso.Login{Store: tt.Store},
 				Token: tt.Token,
-				store: tt.Store,
 			}
 
 			// Run and assert.
@@ -256,12 +261,12 @@ func TestProvider_LoadLoginInfo(t *testing.T) {
 				return
 			}
 
-			if p.loginInfo != nil && p.loginInfo.ClientID != tt.wantID {
+			if p.LoginInfo() != nil && p.LoginInfo().ClientID != tt.wantID {
 				t.Errorf("LoadLoginInfo() incorrect info")
 				return
 			}
 
-			if p.loginInfo != nil && p.DeviceID() != tt.deviceID {
+			if p.LoginInfo() != nil && p.DeviceID() != tt.deviceID {
 				t.Errorf("LoadLoginInfo() incorrect info")
 				return
 			}
@@ -277,6 +282,14 @@ func TestProvider_UpdateLoginInfo(t *testing.T) {
 	b, _ := os.ReadFile(fixtureDir + "/google_discovery_document.json")
 	fixedDiscovery := &DiscoverDoc{}
 	_ = json.Unmarshal(b, fixedDiscovery)
+	jwks, _ := sso.LoadJwksUriv3(ssotest.Certificate)
+	refreshed := fmt.Sprintf(`{"access_token":"a2","expires_in":3599,"id_token":%q}`, ssotest.IDToken(jwt.ClaimSet{
+		"aud":   "c1",
+		"email": "test@exmaple.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iss":   fixedDiscovery.Issuer,
+		"sub":   "load-login-info-good",
+	}))
 
 	tests := []struct {
 		name         string
@@ -311,7 +324,7 @@ func TestProvider_UpdateLoginInfo(t *testing.T) {
 			true,
 		},
 		{
-			"good",
+			"refresh_failed",
 			&Token{
 				info: &jwt.Info{
 					Payload: jwt.ClaimSet{
@@ -327,12 +340,29 @@ func TestProvider_UpdateLoginInfo(t *testing.T) {
 			"load-login-info-good",
 			fixUserAgent,
 			fixedDiscovery,
-			&OAuth2{},
-			&test.MockHttpClient{
-				DoHandler: func(r *http.Request) (*http.Response, error) {
-					return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBuffer([]byte{}))}, nil
+			&OAuth2{ClientID: "c1"},
+			ssotest.Client(map[string]string{}, nil),
+			true,
+		},
+		{
+			"good",
+			&Token{
+				info: &jwt.Info{
+					Payload: jwt.ClaimSet{
+						"sub":   "load-login-info-good",
+						"email": "test@exmaple.com",
+					},
 				},
+				RefreshToken: "abc1234",
 			},
+			fixedStore,
+			tmpDir + "/logins/load-login-info-good.json",
+			"84779adf-91d2-50a4-bffe-ddd2f43b6c53",
+			"load-login-info-good",
+			fixUserAgent,
+			fixedDiscovery,
+			&OAuth2{ClientID: "c1"},
+			ssotest.Client(map[string]string{"/token": refreshed}, nil),
 			false,
 		},
 	}
@@ -340,9 +370,10 @@ func TestProvider_UpdateLoginInfo(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			p := &Provider{
+				Login:        sso.Login{Store: tt.Store},
 				Token:        tt.Token,
-				store:        tt.Store,
 				DiscoveryDoc: tt.discovery,
+				JWKs:         jwks,
 				OAuth2:       tt.oAuth,
 				client:       tt.client,
 			}
@@ -358,10 +389,14 @@ func TestProvider_UpdateLoginInfo(t *testing.T) {
 				return
 			}
 
-			if p.loginInfo != nil && p.loginInfo.ClientID != tt.wantID {
+			if p.LoginInfo() != nil && p.LoginInfo().ClientID != tt.wantID {
 				t.Errorf("LoadLoginInfo() incorrect info")
 				return
 			}
+
+			if !tt.wantErr && tt.oAuth != nil && (p.Token.AccessToken != "a2" || p.LoginInfo().RefreshToken != "abc1234") {
+				t.Errorf("UpdateLoginInfo() did not refresh the token")
+			}
 		})
 	}
 }
@@ -403,8 +438,8 @@ func TestProvider_RegisterLoginInfo(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			p := &Provider{
+				Login: sso.Login{Store: tt.Store},
 				Token: tt.Token,
-				store: tt.Store,
 			}
 
 			// Run and assert.
@@ -414,10 +449,254 @@ func TestProvider_RegisterLoginInfo(t *testing.T) {
 				return
 			}
 
-			if !tt.wantErr && p.loginInfo.ClientID != tt.wantID {
+			if !tt.wantErr && p.LoginInfo().ClientID != tt.wantID {
 				t.Errorf("RegisterLoginInfo() incorrect info")
 				return
 			}
 		})
 	}
 }
+
+// mockHooks Veto adding a device, and keep the events it was called with.
+type mockHooks struct {
+	sso.NopHooks
+	events []*sso.HookEvent
+	veto   bool
+}
+
+func (h *mockHooks) OnNewDevice(event *sso.HookEvent) error {
+	h.events = append(h.events, event)
+	if h.veto {
+		return fmt.Errorf("no new devices")
+	}
+	return nil
+}
+
+func TestProvider_Hooks(t *testing.T) {
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	fixedStore, _ := storage.NewLocalStorage(tmpDir)
+
+	tests := []struct {
+		name    string
+		veto    bool
+		wantErr bool
+	}{
+		{"allow", false, false},
+		{"veto", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := "hooks-" + tt.name
+			hooks := &mockHooks{veto: tt.veto}
+			p := &Provider{
+				Login: sso.Login{Hooks: hooks, Store: fixedStore},
+				Token: &Token{info: &jwt.Info{Payload: jwt.ClaimSet{"sub": sub, "email": "test@example.com"}}},
+			}
+
+			_, err := p.RegisterLoginInfo("a1", "s1", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RegisterLoginInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var vetoed *sso.ErrVetoed
+			if tt.wantErr && !errors.As(err, &vetoed) {
+				t.Errorf("RegisterLoginInfo() error = %v, want an ErrVetoed", err)
+			}
+
+			if len(hooks.events) != 1 || hooks.events[0].Device == nil || hooks.events[0].Claims.Subject != sub {
+				t.Errorf("RegisterLoginInfo() called OnNewDevice with %v", hooks.events)
+			}
+
+			if saved := fixedStore.Exist("logins/" + sub + ".json"); saved == tt.veto {
+				t.Errorf("RegisterLoginInfo() saved the login info = %v, want %v", saved, !tt.veto)
+			}
+		})
+	}
+}
+
+type mockAuditor []*sso.AuditEvent
+
+func (a *mockAuditor) Record(event *sso.AuditEvent) error {
+	*a = append(*a, event)
+	return nil
+}
+
+func TestProvider_RevokeDevice(t *testing.T) {
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	fixedStore, _ := storage.NewLocalStorage(tmpDir)
+	ua := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	token := &Token{info: &jwt.Info{Payload: jwt.ClaimSet{"sub": "revoke-device", "email": "test@example.com"}}}
+
+	p := &Provider{Login: sso.Login{Store: fixedStore}, Token: token}
+	if _, e := p.RegisterLoginInfo("a1", "s1", ua); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := p.RevokeDevice(p.DeviceID()); e != nil {
+		t.Errorf("RevokeDevice() error = %v", e)
+		return
+	}
+
+	// Load it in a later request, as the device signs in again.
+	later := &Provider{Login: sso.Login{Store: fixedStore}, Token: token}
+	_, err := later.LoadLoginInfo(p.DeviceID(), "s1", ua)
+
+	var reauthenticate *sso.ErrReauthenticate
+	if !errors.As(err, &reauthenticate) || later.DeviceID() != "" {
+		t.Errorf("LoadLoginInfo() error = %v, want ErrReauthenticate for the revoked device", err)
+	}
+}
+
+func TestProvider_SignOut(t *testing.T) {
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	fixedStore, _ := storage.NewLocalStorage(tmpDir)
+
+	tests := []struct {
+		name        string
+		status      int
+		token       *Token
+		wantRevoke  string
+		wantRefresh bool
+		wantErr     bool
+	}{
+		{"no_token", 200, nil, "", true, false},
+		{"refresh_token", 200, &Token{AccessToken: "a1", RefreshToken: "r1"}, "token=r1&token_type_hint=refresh_token", false, false},
+		{"access_token", 200, &Token{AccessToken: "a1"}, "token=a1&token_type_hint=access_token", false, false},
+		{"revoke_failed", 400, &Token{AccessToken: "a1", RefreshToken: "r1"}, "token=r1&token_type_hint=refresh_token", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRevoke string
+			session := ssotest.Session{
+				sso.SessionTokenGoogle + sso.SessionState: []byte("s1"),
+				sso.SessionTokenGoogle + sso.SessionNonce: []byte("n1"),
+				"app_value": []byte("keep"),
+			}
+			if tt.token != nil {
+				tt.token.info = &jwt.Info{Payload: jwt.ClaimSet{"sub": "sign-out-" + tt.name, "email": "test@example.com"}}
+			}
+			audit := &mockAuditor{}
+			p := &Provider{
+				Login:        sso.Login{Audit: audit, Store: fixedStore},
+				DiscoveryDoc: &DiscoverDoc{RevocationEndpoint: "https://oauth2.googleapis.com/revoke"},
+				Token:        tt.token,
+				client: &test.MockHttpClient{
+					DoHandler: func(r *http.Request) (*http.Response, error) {
+						b, _ := io.ReadAll(r.Body)
+						gotRevoke = string(b)
+						return &http.Response{StatusCode: tt.status, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+					},
+				},
+				session: session,
+			}
+			p.SetDeviceID("d1")
+			p.SetLoginInfo(&sso.LoginInfo{
+				Devices:      map[string]*sso.Device{"d1": {ID: "d1", SessionID: "session1"}},
+				RefreshToken: "r1",
+			})
+
+			err := p.SignOut()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignOut() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if gotRevoke != tt.wantRevoke {
+				t.Errorf("SignOut() revoked %q, want %q", gotRevoke, tt.wantRevoke)
+			}
+
+			if len(session) != 1 || p.Token != nil {
+				t.Errorf("SignOut() did not clear the session %v", session)
+			}
+
+			if len(*audit) != 1 || (*audit)[0].Type != sso.AuditSignOut || (*audit)[0].DeviceID != "d1" {
+				t.Errorf("SignOut() audit events = %v", *audit)
+			}
+
+			if tt.token == nil {
+				return
+			}
+
+			if p.LoginInfo().Devices["d1"].SessionID != "" || (p.LoginInfo().RefreshToken != nil) != tt.wantRefresh {
+				t.Errorf("SignOut() did not unbind the device")
+			}
+
+			if !fsio.Exist(tmpDir + "/logins/sign-out-" + tt.name + ".json") {
+				t.Errorf("SignOut() did not save the login info")
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateToken(t *testing.T) {
+	b, _ := os.ReadFile(fixtureDir + "/google_discovery_document.json")
+	fixedDiscovery := &DiscoverDoc{}
+	_ = json.Unmarshal(b, fixedDiscovery)
+	jwks, _ := sso.LoadJwksUriv3(ssotest.Certificate)
+	exp := time.Now().Add(5 * time.Minute).Unix()
+
+	tests := []struct {
+		name    string
+		payload jwt.ClaimSet
+		wantErr bool
+	}{
+		{"good", jwt.ClaimSet{"iss": fixedDiscovery.Issuer, "aud": "c1", "exp": exp, "sub": "s1"}, false},
+		{"aud_array", jwt.ClaimSet{"iss": fixedDiscovery.Issuer, "aud": []string{"c0", "c1"}, "exp": exp, "sub": "s1"}, false},
+		{"wrong_aud", jwt.ClaimSet{"iss": fixedDiscovery.Issuer, "aud": []string{"c0", "c2"}, "exp": exp, "sub": "s1"}, true},
+		{"no_aud", jwt.ClaimSet{"iss": fixedDiscovery.Issuer, "exp": exp, "sub": "s1"}, true},
+		{"expired", jwt.ClaimSet{"iss": fixedDiscovery.Issuer, "aud": "c1", "exp": time.Now().Add(-time.Minute).Unix(), "sub": "s1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provider{DiscoveryDoc: fixedDiscovery, JWKs: jwks, OAuth2: &OAuth2{ClientID: "c1"}}
+
+			if err := p.ValidateToken(&Token{IDToken: ssotest.IDToken(tt.payload)}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateToken_Metrics(t *testing.T) {
+	tests := []struct {
+		name  string
+		token *Token
+		jwks  *sso.JwksUriv3
+		want  string
+	}{
+		{"nil_token", nil, nil, `sso_token_validation_failures_total{provider="google",reason="malformed"} 1`},
+		{"no_keys", &Token{info: &jwt.Info{Payload: jwt.ClaimSet{"sub": "s1"}}}, nil, `sso_token_validation_failures_total{provider="google",reason="no_keys"} 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := sso.NewPrometheus()
+			sso.Metrics = m
+			defer func() { sso.Metrics = nil }()
+
+			p := &Provider{JWKs: tt.jwks}
+			if err := p.ValidateToken(tt.token); err == nil {
+				t.Errorf("ValidateToken() error = nil, want an error")
+				return
+			}
+
+			buf := &bytes.Buffer{}
+			_, _ = m.WriteTo(buf)
+			if !strings.Contains(buf.String(), tt.want+"\n") {
+				t.Errorf("ValidateToken() recorded %v, want %v", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_AuthLink_NoSession(t *testing.T) {
+	p := &Provider{
+		DiscoveryDoc: &DiscoverDoc{AuthorizationEndpoint: "https://accounts.google.com/o/oauth2/v2/auth"},
+		OAuth2:       &OAuth2{ClientID: "c1"},
+	}
+
+	var noSession *ErrNoSession
+	if _, e := p.AuthLink(""); !errors.As(e, &noSession) {
+		t.Errorf("AuthLink() error = %v, want ErrNoSession", e)
+	}
+}
@@ -0,0 +1,11 @@
+package microsoft
+
+import "fmt"
+
+type ErrTenantNotAllowed struct {
+	TenantID string
+}
+
+func (e *ErrTenantNotAllowed) Error() string {
+	return fmt.Sprintf(stderr.TenantNotAllowed, e.TenantID)
+}
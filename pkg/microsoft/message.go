@@ -0,0 +1,13 @@
+package microsoft
+
+var stderr = struct {
+	MissEnvVar,
+	NoTenantID,
+	TenantNotAllowed,
+	ValidateTokenIss string
+}{
+	MissEnvVar:       "missing env var: %v",
+	NoTenantID:       "no tid claim found in payload",
+	TenantNotAllowed: "tenant %v is not allowed to sign in",
+	ValidateTokenIss: "invalid iss\nret-iss: %v\norg-iss: %v",
+}
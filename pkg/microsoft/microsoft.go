@@ -0,0 +1,123 @@
+package microsoft
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kohirens/sso"
+	"github.com/kohirens/sso/pkg/oidc"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	envOIDCAllowedTenants = "MICROSOFT_OIDC_ALLOWED_TENANTS"
+	envOIDCClientID       = "MICROSOFT_OIDC_CLIENT_ID"
+	envOIDCClientSecret   = "MICROSOFT_OIDC_CLIENT_SECRET"
+	envOIDCRedirectURIs   = "MICROSOFT_OIDC_REDIRECT_URIS"
+	envOIDCTenant         = "MICROSOFT_OIDC_TENANT"
+
+	// authority Where the Microsoft identity platform v2.0 endpoints live,
+	// the tenant is placed in the middle.
+	authority = "https://login.microsoftonline.com/%v/v2.0"
+	// tenantPlaceholder Stands in for the tenant ID in the issuer of the
+	// discovery document for the multi-tenant endpoints.
+	tenantPlaceholder = "{tenantid}"
+)
+
+// Tenants that are not a single directory, see:
+// https://learn.microsoft.com/en-us/entra/identity-platform/v2-protocols#endpoints
+const (
+	// TenantCommon Work, school, and personal Microsoft accounts.
+	TenantCommon = "common"
+	// TenantOrganizations Work and school accounts only.
+	TenantOrganizations = "organizations"
+	// TenantConsumers Personal Microsoft accounts only.
+	TenantConsumers = "consumers"
+)
+
+// NewAuth Provider Authentication object using credentials found in the
+// environment.
+//
+//	Will look for:
+//	  MICROSOFT_OIDC_CLIENT_ID
+//	  MICROSOFT_OIDC_CLIENT_SECRET
+//	  MICROSOFT_OIDC_REDIRECT_URIS
+//	  MICROSOFT_OIDC_TENANT (optional, defaults to common)
+func NewAuth() (*oidc.OAuth2, error) {
+	clientID, ok1 := os.LookupEnv(envOIDCClientID)
+	if !ok1 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCClientID)
+	}
+
+	clientSecret, ok2 := os.LookupEnv(envOIDCClientSecret)
+	if !ok2 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCClientSecret)
+	}
+
+	redirectURI := os.Getenv(envOIDCRedirectURIs)
+	if redirectURI == "" {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCRedirectURIs)
+	}
+
+	tenant := os.Getenv(envOIDCTenant)
+	if tenant == "" {
+		tenant = TenantCommon
+	}
+
+	return &oidc.OAuth2{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Issuer:       Authority(tenant),
+		RedirectURI:  redirectURI,
+		Scopes:       []string{"openid", "profile", "email", "offline_access"},
+	}, nil
+}
+
+// NewProvider Initialize a Microsoft Entra ID provider to authenticate a
+// client requesting access to your application.
+//
+//	The tenant allowlist is read from MICROSOFT_OIDC_ALLOWED_TENANTS, a comma
+//	separated list of tenant IDs. When empty, any tenant is accepted.
+func NewProvider(client sso.HttpClient, store storage.Storage, session sso.SessionManager, prefix string) (*Provider, error) {
+	oauth2, e1 := NewAuth()
+	if e1 != nil {
+		return nil, e1
+	}
+
+	p := &Provider{
+		Provider: oidc.New("microsoft", oauth2, client, store, session, prefix),
+	}
+
+	for _, tid := range strings.Split(os.Getenv(envOIDCAllowedTenants), ",") {
+		if tid = strings.TrimSpace(tid); tid != "" {
+			p.Tenants = append(p.Tenants, tid)
+		}
+	}
+
+	// The object ID is the same across applications in a tenant, where sub
+	// is different for each application.
+	p.SubjectClaim = "oid"
+	// Entra ID only sends the email claim for users with a mailbox, when the
+	// optional claim is set up, so many work and school accounts have none.
+	// Other claims, such as preferred_username, are not verified emails.
+	p.EmailOptional = true
+	p.ValidateIssuer = p.validateIssuer
+
+	if e := p.LoadDiscoveryDoc(); e != nil {
+		return p, e
+	}
+
+	if e := p.LoadCertificate(); e != nil {
+		return p, e
+	}
+
+	return p, nil
+}
+
+// Authority The issuer URL for a tenant, which can be a tenant ID, a
+// verified domain, or one of TenantCommon, TenantOrganizations or
+// TenantConsumers.
+func Authority(tenant string) string {
+	return fmt.Sprintf(authority, tenant)
+}
@@ -0,0 +1,76 @@
+package microsoft
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso"
+	"github.com/kohirens/sso/pkg/oidc"
+)
+
+// Provider Microsoft Entra ID (Azure AD), a standard OIDC provider except for
+// the issuer, which differs for every tenant when signing in through the
+// common or organizations endpoints.
+type Provider struct {
+	*oidc.Provider
+	// Tenants The tenant IDs allowed to sign in, the Entra equivalent of
+	// restricting Google to a hosted domain. Any tenant is allowed when empty.
+	Tenants []string `json:"tenants"`
+}
+
+var _ sso.OIDCProvider = (*Provider)(nil)
+
+// ParseTenantID Return the tenant (directory) the logged-in client belongs
+// to, or an error when there is no token, or it has no tid claim.
+func (p *Provider) ParseTenantID() (string, error) {
+	if p.Token == nil {
+		return "", fmt.Errorf("%v", stderr.NoTenantID)
+	}
+
+	idToken, e1 := p.Token.IDTokenInfo()
+	if e1 != nil {
+		return "", e1
+	}
+
+	tid, ok := idToken.Payload["tid"].(string)
+	if !ok || tid == "" {
+		return "", fmt.Errorf("%v", stderr.NoTenantID)
+	}
+
+	return tid, nil
+}
+
+// TenantID The tenant (directory) the logged-in client belongs to, or an
+// empty string when it is not known, see ParseTenantID.
+func (p *Provider) TenantID() string {
+	tid, e1 := p.ParseTenantID()
+	if e1 != nil {
+		oidc.Log.Errf("%v", e1.Error())
+	}
+
+	return tid
+}
+
+// validateIssuer Verify the iss claim using the tid claim in place of the
+// tenant ID placeholder in the issuer of the discovery document, and that the
+// tenant is allowed, see:
+// https://learn.microsoft.com/en-us/entra/identity-platform/access-tokens#validate-the-issuer
+func (p *Provider) validateIssuer(iss string, claims jwt.ClaimSet) error {
+	tid, ok := claims["tid"].(string)
+	if !ok || tid == "" {
+		return fmt.Errorf("%v", stderr.NoTenantID)
+	}
+
+	if len(p.Tenants) > 0 && !slices.Contains(p.Tenants, tid) {
+		return &ErrTenantNotAllowed{tid}
+	}
+
+	want := strings.Replace(p.DiscoveryDoc.Issuer, tenantPlaceholder, tid, 1)
+	if iss != want {
+		return fmt.Errorf(stderr.ValidateTokenIss, iss, want)
+	}
+
+	return nil
+}
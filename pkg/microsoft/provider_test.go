@@ -0,0 +1,157 @@
+package microsoft
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso/internal/ssotest"
+	"github.com/kohirens/sso/pkg/oidc"
+	"github.com/kohirens/stdlib/test"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	fixtureDir = "testdata"
+	tmpDir     = "tmp"
+	fixTenant  = "9122040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+func TestMain(m *testing.M) {
+	test.ResetDir(tmpDir, 0777)
+
+	os.Exit(m.Run())
+}
+
+// fixtureClient Serve the discovery document from the testdata directory and
+// the shared JWKs.
+func fixtureClient() *test.MockHttpClient {
+	dd, _ := os.ReadFile(fixtureDir + "/discovery_document.json")
+
+	return ssotest.Client(map[string]string{
+		"/.well-known/openid-configuration": string(dd),
+		"/keys":                             string(ssotest.Certificate),
+	}, nil)
+}
+
+func TestProvider_ValidateToken(t *testing.T) {
+	t.Setenv("MICROSOFT_OIDC_CLIENT_ID", "test-client")
+	t.Setenv("MICROSOFT_OIDC_CLIENT_SECRET", "1234")
+	t.Setenv("MICROSOFT_OIDC_REDIRECT_URIS", "https://example.com/callback")
+
+	exp := time.Now().Add(5 * time.Minute).Unix()
+	goodIss := "https://login.microsoftonline.com/" + fixTenant + "/v2.0"
+
+	tests := []struct {
+		name    string
+		allowed string
+		payload jwt.ClaimSet
+		wantErr bool
+	}{
+		{
+			"good",
+			"",
+			jwt.ClaimSet{"iss": goodIss, "tid": fixTenant, "aud": "test-client", "exp": exp, "oid": "o1", "sub": "s1"},
+			false,
+		},
+		{
+			"allowed_tenant",
+			"11111111-2222-3333-4444-555555555555, " + fixTenant,
+			jwt.ClaimSet{"iss": goodIss, "tid": fixTenant, "aud": "test-client", "exp": exp, "oid": "o1", "sub": "s1"},
+			false,
+		},
+		{
+			"tenant_not_allowed",
+			"11111111-2222-3333-4444-555555555555",
+			jwt.ClaimSet{"iss": goodIss, "tid": fixTenant, "aud": "test-client", "exp": exp, "oid": "o1", "sub": "s1"},
+			true,
+		},
+		{
+			"iss_of_another_tenant",
+			"",
+			jwt.ClaimSet{"iss": goodIss, "tid": "11111111-2222-3333-4444-555555555555", "aud": "test-client", "exp": exp, "oid": "o1", "sub": "s1"},
+			true,
+		},
+		{
+			"no_tid",
+			"",
+			jwt.ClaimSet{"iss": goodIss, "aud": "test-client", "exp": exp, "oid": "o1", "sub": "s1"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MICROSOFT_OIDC_ALLOWED_TENANTS", tt.allowed)
+			store, _ := storage.NewLocalStorage(tmpDir)
+
+			p, e1 := NewProvider(fixtureClient(), store, nil, "")
+			if e1 != nil {
+				t.Errorf("NewProvider() error = %v", e1)
+				return
+			}
+
+			token := &oidc.Token{IDToken: ssotest.IDToken(tt.payload)}
+			err := p.ValidateToken(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			p.Token = token
+			if p.ClientID() != "o1" || p.TenantID() != fixTenant {
+				t.Errorf("ClientID() = %v, want the oid claim", p.ClientID())
+			}
+		})
+	}
+}
+
+func TestAuthority(t *testing.T) {
+	if got := Authority(TenantOrganizations); got != "https://login.microsoftonline.com/organizations/v2.0" {
+		t.Errorf("Authority() = %v", got)
+	}
+}
+
+func TestProvider_LoginInfo_NoEmail(t *testing.T) {
+	t.Setenv("MICROSOFT_OIDC_CLIENT_ID", "test-client")
+	t.Setenv("MICROSOFT_OIDC_CLIENT_SECRET", "1234")
+	t.Setenv("MICROSOFT_OIDC_REDIRECT_URIS", "https://example.com/callback")
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmpDir)
+	ua := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+	p, e1 := NewProvider(fixtureClient(), store, nil, "")
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	// A work account without a mailbox has no email claim.
+	p.Token = &oidc.Token{IDToken: ssotest.IDToken(jwt.ClaimSet{
+		"iss":                "https://login.microsoftonline.com/" + fixTenant + "/v2.0",
+		"tid":                fixTenant,
+		"aud":                "test-client",
+		"exp":                time.Now().Add(5 * time.Minute).Unix(),
+		"oid":                "no-email",
+		"preferred_username": "crowbar@contoso.onmicrosoft.com",
+		"sub":                "s1",
+	})}
+
+	li, e2 := p.RegisterLoginInfo("a1", "4321", ua)
+	if e2 != nil {
+		t.Errorf("RegisterLoginInfo() error = %v", e2)
+		return
+	}
+
+	if li.Email != "" || !store.Exist("logins/no-email.json") {
+		t.Errorf("RegisterLoginInfo() email = %q, want it empty and saved under the oid", li.Email)
+		return
+	}
+
+	if e := p.UpdateLoginInfo(p.DeviceID(), "5678", ua); e != nil {
+		t.Errorf("UpdateLoginInfo() error = %v", e)
+	}
+}
@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso"
+)
+
+// OAuth2 The credentials of the client registered with the identity provider
+// for this application.
+type OAuth2 struct {
+	ClientID     string
+	ClientSecret string
+	// Issuer URL of the identity provider, for example
+	// https://keycloak.example.com/realms/example, the discovery document is
+	// expected at <Issuer>/.well-known/openid-configuration.
+	Issuer      string
+	RedirectURI string
+	// Scopes to request, defaults to openid, profile, and email.
+	Scopes []string
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`            // AccessToken A token that can be sent to the identity provider APIs.
+	ExpiresIn    int    `json:"expires_in"`              // ExpiresIn The remaining lifetime of the access token in seconds.
+	IDToken      string `json:"id_token"`                // IDToken A JWT that contains identity information about the user.
+	Scope        string `json:"scope"`                   // Scope The scopes of access granted by the access_token expressed as a list of space-delimited, case-sensitive strings.
+	TokenType    string `json:"token_type"`              // TokenType Identifies the type of token returned, normally Bearer.
+	RefreshToken string `json:"refresh_token,omitempty"` // RefreshToken (optional) Only present when the offline_access scope was granted.
+	info         *jwt.Info
+	claims       *sso.IDTokenClaims
+	Exp          *time.Time
+}
+
+// Claims Decode the claims of the ID token, this is only done once.
+func (t *Token) Claims() (*sso.IDTokenClaims, error) {
+	if t.claims == nil {
+		info, e1 := t.IDTokenInfo()
+		if e1 != nil {
+			return nil, e1
+		}
+
+		claims, e2 := sso.LoadIDTokenClaims(info.Payload)
+		if e2 != nil {
+			return nil, e2
+		}
+		t.claims = claims
+	}
+
+	return t.claims, nil
+}
+
+func (t *Token) Expired() bool {
+	return t.Exp != nil && t.Exp.Before(time.Now().UTC())
+}
+
+// IDTokenInfo Convert the ID token string into code we can use to extract
+// values that will be used to validate it.
+// NOTE: This is NOT what performs validation, but aids in the process.
+func (t *Token) IDTokenInfo() (*jwt.Info, error) {
+	if t.info == nil {
+		info, e1 := jwt.Parse(t.IDToken)
+		if e1 != nil {
+			return nil, e1
+		}
+		t.info = info
+	}
+	return t.info, nil
+}
+
+// DiscoveryURL Location of the discovery document for the issuer.
+func (o *OAuth2) DiscoveryURL() string {
+	return strings.TrimSuffix(o.Issuer, "/") + wellKnownPath
+}
+
+// loadToken Convert token data to a Token, then close it.
+func loadToken(rc io.ReadCloser) (*Token, error) {
+	defer func() { _ = rc.Close() }()
+
+	resBody, e2 := io.ReadAll(rc)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ReadResponse, e2.Error())
+	}
+
+	token := &Token{}
+	if e := json.Unmarshal(resBody, token); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	exp := time.Now().UTC().Add(time.Duration(token.ExpiresIn) * time.Second)
+	token.Exp = &exp
+
+	return token, nil
+}
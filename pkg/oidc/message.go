@@ -0,0 +1,53 @@
+package oidc
+
+var stderr = struct {
+	DecodeJSON,
+	DiscoveryTokenURI,
+	EncodeJSON,
+	IDTokenNoEmail,
+	IDTokenNoSub,
+	IssuerMismatch,
+	MissEnvVar,
+	NoAuthEndpoint,
+	NoCerts,
+	OAuth2Nil,
+	ParsingIDToken,
+	ReadResponse,
+	Response,
+	SignatureVerify,
+	SignOut,
+	ValidateTokenAud,
+	ValidateTokenAzp,
+	ValidateTokenExp,
+	ValidateTokenIss,
+	ValidateTokenNil string
+}{
+	DecodeJSON:        "could not decode JSON: %v",
+	DiscoveryTokenURI: "discovery document token endpoint is empty",
+	EncodeJSON:        "unable encode JSON: %v",
+	IDTokenNoEmail:    "no email claim found in payload",
+	IDTokenNoSub:      "no %v claim found in payload",
+	IssuerMismatch:    "discovery document issuer %v does not match the configured issuer %v",
+	MissEnvVar:        "missing env var: %v",
+	NoAuthEndpoint:    "discovery document authorization endpoint is empty",
+	NoCerts:           "no certificates to validate token",
+	OAuth2Nil:         "no oauth2 credentials are set",
+	ParsingIDToken:    "error parsing ID token: %v",
+	ReadResponse:      "could not read response: %v",
+	Response:          "not the expected response: %v",
+	SignatureVerify:   "signature verification failed: %v",
+	SignOut:           "signing out failed: %v",
+	ValidateTokenAud:  "invalid aud\nret-aud: %v\norg-aud: %v",
+	ValidateTokenAzp:  "invalid azp\nret-azp: %v\norg-azp: %v",
+	ValidateTokenExp:  "token has expired",
+	ValidateTokenIss:  "invalid iss: %v",
+	ValidateTokenNil:  "token is nil",
+}
+
+var stdout = struct {
+	TokenExp,
+	TokenUri string
+}{
+	TokenExp: "%v has provided a token that expires in %v seconds",
+	TokenUri: "%v OIDC Token URI: %v",
+}
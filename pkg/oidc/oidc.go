@@ -0,0 +1,116 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kohirens/sso"
+	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	envOIDCClientID     = "OIDC_CLIENT_ID"
+	envOIDCClientSecret = "OIDC_CLIENT_SECRET"
+	envOIDCIssuer       = "OIDC_ISSUER"
+	envOIDCRedirectURIs = "OIDC_REDIRECT_URIS"
+	envOIDCScopes       = "OIDC_SCOPES"
+
+	keyDiscoveryDoc = "discovery_document"
+	keyCertificate  = "certificate"
+
+	// wellKnownPath Appended to the issuer to locate the discovery document,
+	// see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfig
+	wellKnownPath = "/.well-known/openid-configuration"
+)
+
+// Log A logger that follows the Kohirens standard of logging; where a human
+// comprehensible error message is treated as equal to an error code. Having
+// either one should point directly to where to problem in the code lies. In
+// fact the error code can be omitted if so desired.
+var Log = &logger.Standard{}
+
+// NewAuth Provider Authentication object using credentials found in the
+// environment.
+//
+//	Will look for:
+//	  OIDC_ISSUER
+//	  OIDC_CLIENT_ID
+//	  OIDC_CLIENT_SECRET
+//	  OIDC_REDIRECT_URIS
+//	  OIDC_SCOPES (optional, space separated)
+func NewAuth() (*OAuth2, error) {
+	issuer, ok1 := os.LookupEnv(envOIDCIssuer)
+	if !ok1 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCIssuer)
+	}
+
+	clientID, ok2 := os.LookupEnv(envOIDCClientID)
+	if !ok2 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCClientID)
+	}
+
+	clientSecret, ok3 := os.LookupEnv(envOIDCClientSecret)
+	if !ok3 {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCClientSecret)
+	}
+
+	redirectURI := os.Getenv(envOIDCRedirectURIs)
+	if redirectURI == "" {
+		return nil, fmt.Errorf(stderr.MissEnvVar, envOIDCRedirectURIs)
+	}
+
+	return &OAuth2{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Issuer:       issuer,
+		RedirectURI:  redirectURI,
+		Scopes:       strings.Fields(os.Getenv(envOIDCScopes)),
+	}, nil
+}
+
+// New Initialize an OIDC provider without loading the discovery document nor
+// the JWKs. This allows a provider built on this one to adjust it before
+// calling LoadDiscoveryDoc and LoadCertificate. Otherwise use NewProvider.
+func New(name string, oauth2 *OAuth2, client sso.HttpClient, store storage.Storage, session sso.SessionManager, prefix string) *Provider {
+	scopes := []string{"openid", "profile", "email"}
+	if oauth2 != nil && len(oauth2.Scopes) > 0 {
+		scopes = oauth2.Scopes
+	}
+
+	return &Provider{
+		Login:        sso.Login{Store: store},
+		DiscoveryDoc: &sso.DiscoverDoc{},
+		OAuth2:       oauth2,
+		Scopes:       scopes,
+		State:        sso.NewState(),
+		SubjectClaim: "sub",
+		client:       client,
+		name:         name,
+		session:      session,
+		Prefix:       prefix,
+	}
+}
+
+// NewProvider Initialize an OIDC provider for any identity provider that
+// publishes a discovery document, such as Keycloak, Okta, Auth0 or
+// Authentik. The name identifies the provider, it is used for the storage
+// location of cached documents and as the OIDC provider on devices.
+func NewProvider(name string, oauth2 *OAuth2, client sso.HttpClient, store storage.Storage, session sso.SessionManager, prefix string) (*Provider, error) {
+	if oauth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	p := New(name, oauth2, client, store, session, prefix)
+
+	if e := p.LoadDiscoveryDoc(); e != nil {
+		return p, e
+	}
+
+	if e := p.LoadCertificate(); e != nil {
+		return p, e
+	}
+
+	return p, nil
+}
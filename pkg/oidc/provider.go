@@ -0,0 +1,692 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso"
+)
+
+// IssuerValidator Verify the iss claim of an ID token, for identity providers
+// whose issuer is not a single fixed value.
+type IssuerValidator func(iss string, claims jwt.ClaimSet) error
+
+type Provider struct {
+	sso.Login
+	// DiscoveryDoc contains well known info about the identity provider.
+	DiscoveryDoc *sso.DiscoverDoc `json:"discoveryDocument"`
+	// EmailOptional Whether login info can be registered and updated from an
+	// ID token without an email claim, for identity providers that only send
+	// it for some users. The email is then left empty.
+	EmailOptional bool           `json:"email_optional"`
+	JWKs          *sso.JwksUriv3 `json:"keys"`
+	// OAuth2 The issuer and credentials of the client registered with the
+	// identity provider for this application.
+	OAuth2 *OAuth2
+	// PKCE Whether to use a code challenge, by default only when the
+	// discovery document advertises the S256 method.
+	PKCE   sso.PKCEMode `json:"pkce"`
+	Scopes []string     `json:"scopes"`
+	State  string       `json:"state"`
+	// SubjectClaim The claim that identifies the user, defaults to sub.
+	SubjectClaim string `json:"subject_claim"`
+	Token        *Token `json:"credentials"`
+	// ValidateIssuer When set, replaces the exact comparison of the iss claim
+	// with the issuer of the discovery document, including the check that
+	// the discovery document belongs to the configured issuer.
+	ValidateIssuer IssuerValidator `json:"-"`
+	client         sso.HttpClient
+	name           string
+	Prefix         string
+	session        sso.SessionManager
+}
+
+var _ sso.OIDCProvider = (*Provider)(nil)
+
+// Application The client ID registered with the identity provider.
+func (p *Provider) Application() string {
+	return p.OAuth2.ClientID
+}
+
+// Authenticated Indicates if the client has been successfully authenticated.
+func (p *Provider) Authenticated() bool {
+	return p.Token != nil && !p.Token.Expired()
+}
+
+// AuthLink Generate a link to authenticate with the provider.
+func (p *Provider) AuthLink(loginHint string) (string, error) {
+	epAuthentication := p.DiscoveryDoc.AuthorizationEndpoint
+	if epAuthentication == "" {
+		return "", fmt.Errorf("%v", stderr.NoAuthEndpoint)
+	}
+
+	if e := sso.SaveState(p.session, p.sessionKey(sso.SessionState), p.State); e != nil {
+		return "", e
+	}
+
+	nonce, e1 := sso.SaveNonce(p.session, p.sessionKey(sso.SessionNonce))
+	if e1 != nil {
+		return "", e1
+	}
+
+	uri := fmt.Sprintf(
+		"%v?response_type=code&scope=%v&redirect_uri=%v&client_id=%v&state=%v&nonce=%v",
+		epAuthentication,
+		strings.Join(p.Scopes, "%20"),
+		url.QueryEscape(p.OAuth2.RedirectURI),
+		url.QueryEscape(p.OAuth2.ClientID),
+		p.State,
+		nonce,
+	)
+
+	if loginHint != "" {
+		uri = uri + "&login_hint=" + url.QueryEscape(loginHint)
+	}
+
+	pkce, e2 := sso.PKCEChallenge(p.PKCE, p.DiscoveryDoc, p.session, p.sessionKey(sso.SessionCodeVerifier))
+	if e2 != nil {
+		return "", e2
+	}
+	uri = uri + pkce
+
+	Log.Dbugf("%v OIDC Auth URI: %s", p.name, uri)
+
+	return uri, nil
+}
+
+// Certificate Download the JWKs for validating ID tokens.
+func (p *Provider) Certificate() error {
+	return sso.RefreshDocument(
+		p.client,
+		p.Store,
+		p.location(p.name+"_"+keyCertificate),
+		p.DiscoveryDoc.JwksUri,
+		p.setCertificate,
+	)
+}
+
+// ClientEmail Return the logged in clients email address.
+func (p *Provider) ClientEmail() string {
+	email, e1 := p.ParseClientEmail()
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+	}
+
+	return email
+}
+
+// ClientID The sub claim of the ID token, an identifier for the user that is
+// unique within the issuer and never reassigned. Set SubjectClaim to use a
+// different claim.
+func (p *Provider) ClientID() string {
+	clientID, e1 := p.ParseClientID()
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+	}
+
+	return clientID
+}
+
+// ExchangeCodeForToken Trade the authorization code returned to the callback
+// for an ID token.
+func (p *Provider) ExchangeCodeForToken(state, code string) error {
+	if e := p.VerifyState(state); e != nil {
+		return e
+	}
+
+	nonce, e3 := sso.LoadNonce(p.session, p.sessionKey(sso.SessionNonce))
+	if e3 != nil {
+		return e3
+	}
+
+	pkce, e2 := sso.PKCEVerifier(p.PKCE, p.DiscoveryDoc, p.session, p.sessionKey(sso.SessionCodeVerifier))
+	if e2 != nil {
+		return e2
+	}
+
+	reqBody := fmt.Sprintf(
+		"code=%v&redirect_uri=%v&grant_type=authorization_code%v",
+		url.QueryEscape(code),
+		url.QueryEscape(p.OAuth2.RedirectURI),
+		pkce,
+	)
+
+	token, e1 := p.requestToken("authorization_code", reqBody)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := p.ValidateToken(token); e != nil {
+		return e
+	}
+
+	// Verify the nonce claim matches the nonce sent with the login, so that
+	// an ID token cannot be replayed.
+	claims, e4 := token.Claims()
+	if e4 != nil {
+		return fmt.Errorf(stderr.ParsingIDToken, e4.Error())
+	}
+
+	if e := sso.VerifyNonce(nonce, claims.Nonce); e != nil {
+		return e
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookLogin, id, p.LoginInfo(), nil); e != nil {
+		return e
+	}
+
+	p.Token = token
+
+	Log.Dbugf(stdout.TokenExp, p.name, p.Token.ExpiresIn)
+
+	return nil
+}
+
+// FinishDeviceLogin Wait for the user to approve the device, with the codes
+// from StartDeviceLogin, then validate the ID token and load the login info
+// of the device, registering it on the first login.
+func (p *Provider) FinishDeviceLogin(ctx context.Context, da *sso.DeviceAuthorization, accountID, deviceID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	if p.OAuth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	headers := http.Header{}
+	reqBody := p.clientAuth(fmt.Sprintf(
+		"device_code=%v&grant_type=%v",
+		url.QueryEscape(da.DeviceCode),
+		url.QueryEscape(sso.GrantTypeDeviceCode),
+	), headers)
+
+	data, e1 := sso.PollDeviceToken(ctx, p.client, p.DiscoveryDoc.TokenEndpoint, reqBody, headers, da)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	token, e2 := loadToken(io.NopCloser(bytes.NewReader(data)))
+	if e2 != nil {
+		return nil, e2
+	}
+
+	if e := p.ValidateToken(token); e != nil {
+		return nil, e
+	}
+
+	id, e3 := p.identity(token)
+	if e3 != nil {
+		return nil, e3
+	}
+
+	if e := p.RunHook(sso.HookLogin, id, nil, nil); e != nil {
+		return nil, e
+	}
+
+	p.Token = token
+
+	return p.SignIn(id, accountID, deviceID, sessionID, userAgent)
+}
+
+// IDTokenClaims Return the claims of the ID token.
+func (p *Provider) IDTokenClaims() (*sso.IDTokenClaims, error) {
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	claims, e1 := p.Token.Claims()
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	return claims, nil
+}
+
+// LoadCertificate Load the JWKs, try from cache first, then download from the
+// internet if that fails.
+func (p *Provider) LoadCertificate() error {
+	return sso.LoadDocument(
+		p.client,
+		p.Store,
+		p.location(p.name+"_"+keyCertificate),
+		p.DiscoveryDoc.JwksUri,
+		p.setCertificate,
+	)
+}
+
+// LoadDiscoveryDoc Load the discovery document of the issuer, try from cache
+// first, then download from the internet if that fails.
+//
+//	The issuer in the document MUST match the issuer configured, otherwise it
+//	is rejected.
+func (p *Provider) LoadDiscoveryDoc() error {
+	if p.OAuth2 == nil {
+		return fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	return sso.LoadDocument(
+		p.client,
+		p.Store,
+		p.location(p.name+"_"+keyDiscoveryDoc),
+		p.OAuth2.DiscoveryURL(),
+		func(data []byte) error {
+			doc, e := sso.LoadDiscoverDoc(data)
+			if e != nil {
+				return e
+			}
+			if p.ValidateIssuer == nil && strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.OAuth2.Issuer, "/") {
+				return fmt.Errorf(stderr.IssuerMismatch, doc.Issuer, p.OAuth2.Issuer)
+			}
+			p.DiscoveryDoc = doc
+			return nil
+		},
+	)
+}
+
+// LoadLoginInfo retrieve previous login info from storage.
+//
+//	NOTE: This requires the client to have consented beforehand. The
+//	best time to call this method is during or right after the callback.
+func (p *Provider) LoadLoginInfo(deviceID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return p.Open(id, deviceID, sessionID, userAgent)
+}
+
+// Name ID of the OIDC provider, as given to NewProvider.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// ParseClientEmail Return the logged in clients email address from the ID
+// token, or an error when there is no token, or it has no email, unless
+// EmailOptional is set.
+func (p *Provider) ParseClientEmail() (string, error) {
+	claims, e1 := p.IDTokenClaims()
+	if e1 != nil {
+		return "", e1
+	}
+
+	if claims.Email == "" && !p.EmailOptional {
+		return "", fmt.Errorf("%v", stderr.IDTokenNoEmail)
+	}
+
+	return claims.Email, nil
+}
+
+// ParseClientID Return the SubjectClaim of the ID token, or an error when
+// there is no token, or it does not have the claim.
+func (p *Provider) ParseClientID() (string, error) {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return "", e1
+	}
+
+	return id.Subject, nil
+}
+
+// RefreshToken Get a new token from the identity provider.
+func (p *Provider) RefreshToken() error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	reqBody := fmt.Sprintf(
+		"refresh_token=%v&grant_type=refresh_token",
+		url.QueryEscape(p.Token.RefreshToken),
+	)
+
+	token, e1 := p.requestToken("refresh_token", reqBody)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := p.ValidateToken(token); e != nil {
+		return e
+	}
+
+	// Refresh token rotation is optional, so keep the one we have.
+	if token.RefreshToken == "" {
+		token.RefreshToken = p.Token.RefreshToken
+	}
+
+	id, _ := p.identity(token)
+	if e := p.RunHook(sso.HookRefresh, id, p.LoginInfo(), nil); e != nil {
+		return e
+	}
+
+	p.Token = token
+
+	return nil
+}
+
+// RegisterLoginInfo Register new login information.
+//
+//	NOTE: This is the only time the user agent is set on a device.
+func (p *Provider) RegisterLoginInfo(accountID, sessionID, userAgent string) (*sso.LoginInfo, error) {
+	// Token must be set.
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return nil, e
+	}
+
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return p.Register(id, accountID, sessionID, userAgent)
+}
+
+// RevokeDevice Sign the device out and stop trusting it, so that it must
+// authenticate again, then save the login info.
+func (p *Provider) RevokeDevice(deviceID string) error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Revoke(id, deviceID)
+}
+
+// SaveLoginInfo Save info for retrieval without hitting the identity
+// provider.
+func (p *Provider) SaveLoginInfo() error {
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Save(id)
+}
+
+// SignOut Revoke the token with the provider, so the user must consent again
+// to sign in to your application. Will also remove any data stored in the
+// session, and unbind the session from the device.
+func (p *Provider) SignOut() error {
+	var revoke func() error
+	if p.Token != nil {
+		revoke = p.revokeToken
+	}
+
+	id, _ := p.identity(p.Token)
+	err := p.Login.SignOut(id, revoke)
+
+	if e := sso.ClearSession(p.session, p.sessionKey); e != nil {
+		Log.Warnf(stderr.SignOut, e.Error())
+	}
+
+	p.Token = nil
+
+	return err
+}
+
+// StartDeviceLogin Begin a login on a device without a browser, such as a TV
+// or CLI. Show the user code and verification URI to the user, then call
+// FinishDeviceLogin.
+func (p *Provider) StartDeviceLogin() (*sso.DeviceAuthorization, error) {
+	if p.OAuth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	headers := http.Header{}
+	reqBody := p.clientAuth("scope="+strings.Join(p.Scopes, "%20"), headers)
+
+	return sso.StartDeviceAuthorization(p.client, p.DiscoveryDoc.DeviceAuthorizationEndpoint, reqBody, headers)
+}
+
+// UpdateLoginInfo Address changes in the users login information, list the
+// devices, last activity time, etc.
+//
+//	NOTE: Never update the provider ClientID nor the user agent on the device,
+//	these are only set on registration.
+func (p *Provider) UpdateLoginInfo(deviceID, sessionID, userAgent string) error {
+	if p.Token == nil {
+		return &ErrNoToken{}
+	}
+
+	if _, e := p.ParseClientEmail(); e != nil {
+		return e
+	}
+
+	id, e1 := p.identity(p.Token)
+	if e1 != nil {
+		return e1
+	}
+
+	return p.Update(id, deviceID, sessionID, userAgent)
+}
+
+// UserInfo Get the profile of the client, such as their name and picture,
+// from the userinfo endpoint of the provider. Falls back to the claims in the
+// ID token when the provider has no userinfo endpoint, or it cannot be
+// reached.
+func (p *Provider) UserInfo() (*sso.Profile, error) {
+	if p.Token == nil {
+		return nil, &ErrNoToken{}
+	}
+
+	info, e1 := p.Token.IDTokenInfo()
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	return sso.UserInfo(p.client, p.DiscoveryDoc.UserinfoEndpoint, p.Token.AccessToken, info.Payload)
+}
+
+// ValidateToken Validate an ID token came from the issuer, see:
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (p *Provider) ValidateToken(token *Token) error {
+	reason, err := p.validateToken(token)
+	sso.CountValidation(p.Name(), reason)
+
+	return err
+}
+
+// VerifyState Verify the state returned from the request matches the
+// original value sent.
+func (p *Provider) VerifyState(returnedState string) error {
+	return sso.VerifyState(p.session, p.sessionKey(sso.SessionState), returnedState)
+}
+
+// clientAuth Add the client credentials to a request, in the body with
+// client_secret_post, or in the header when the provider only supports
+// client_secret_basic.
+func (p *Provider) clientAuth(reqBody string, headers http.Header) string {
+	methods := p.DiscoveryDoc.TokenEndpointAuthMethodsSupported
+	if len(methods) > 0 && !slices.Contains(methods, "client_secret_post") && slices.Contains(methods, "client_secret_basic") {
+		credentials := url.QueryEscape(p.OAuth2.ClientID) + ":" + url.QueryEscape(p.OAuth2.ClientSecret)
+		headers.Add("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+		return reqBody
+	}
+
+	return fmt.Sprintf(
+		"client_id=%v&client_secret=%v&%v",
+		url.QueryEscape(p.OAuth2.ClientID),
+		url.QueryEscape(p.OAuth2.ClientSecret),
+		reqBody,
+	)
+}
+
+// identity Who the token signed in, filled in as far as the token allows,
+// with an error when that is not enough to find their login info. The
+// subject is the SubjectClaim.
+func (p *Provider) identity(token *Token) (*sso.Identity, error) {
+	id := &sso.Identity{Provider: p.Name()}
+	if token == nil {
+		return id, &ErrNoToken{}
+	}
+
+	id.RefreshToken = token.RefreshToken
+
+	info, e1 := token.IDTokenInfo()
+	if e1 != nil {
+		return id, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	claims, e2 := token.Claims()
+	if e2 != nil {
+		return id, fmt.Errorf(stderr.ParsingIDToken, e2.Error())
+	}
+
+	claim := p.SubjectClaim
+	if claim == "" {
+		claim = "sub"
+	}
+
+	id.Claims = claims
+	id.Email = claims.Email
+	id.Subject, _ = info.Payload[claim].(string)
+
+	if id.Subject == "" {
+		return id, fmt.Errorf(stderr.IDTokenNoSub, claim)
+	}
+
+	id.Location = p.location("logins/" + id.Subject)
+
+	return id, nil
+}
+
+// location Return the storage location.
+func (p *Provider) location(filename string) string {
+	return sso.Location(p.Prefix, filename)
+}
+
+// requestToken Post to the token endpoint, authenticating the client with
+// client_secret_post, or client_secret_basic when that is the only method
+// the identity provider supports. The request is timed under the grant.
+func (p *Provider) requestToken(grant, reqBody string) (*Token, error) {
+	uri := p.DiscoveryDoc.TokenEndpoint
+	if uri == "" {
+		return nil, fmt.Errorf("%v", stderr.DiscoveryTokenURI)
+	}
+
+	if p.OAuth2 == nil {
+		return nil, fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	Log.Dbugf(stdout.TokenUri, p.name, uri)
+
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/x-www-form-urlencoded")
+	reqBody = p.clientAuth(reqBody, headers)
+
+	start := time.Now()
+	res, e1 := sso.SendWithRetry(p.client, "POST", uri, []byte(reqBody), headers, http.StatusOK, 3)
+	sso.ObserveTokenRequest(p.Name(), grant, start, res)
+	if res == nil {
+		return nil, fmt.Errorf(stderr.Response, e1)
+	}
+
+	return loadToken(res.Body)
+}
+
+// refreshCertificate Download the JWKs again, for when the keys were rotated.
+func (p *Provider) refreshCertificate() (*sso.JwksUriv3, error) {
+	if e := p.Certificate(); e != nil {
+		return nil, e
+	}
+
+	return p.JWKs, nil
+}
+
+// revokeToken Revoke the refresh token, or the access token when there is no
+// refresh token, at the revocation endpoint of the provider.
+func (p *Provider) revokeToken() error {
+	if p.OAuth2 == nil {
+		return fmt.Errorf("%v", stderr.OAuth2Nil)
+	}
+
+	token, hint := p.Token.RefreshToken, "refresh_token"
+	if token == "" {
+		token, hint = p.Token.AccessToken, "access_token"
+	}
+
+	headers := http.Header{}
+	reqBody := p.clientAuth(fmt.Sprintf("token=%v&token_type_hint=%v", url.QueryEscape(token), hint), headers)
+
+	return sso.RevokeToken(p.client, p.DiscoveryDoc.RevocationEndpoint, reqBody, headers)
+}
+
+// sessionKey Where a value is kept in the session for this provider.
+func (p *Provider) sessionKey(name string) string {
+	return "__" + p.name + "__" + name
+}
+
+// setCertificate Decode the JWKs used to validate ID tokens.
+func (p *Provider) setCertificate(data []byte) error {
+	jwks, e := sso.LoadJwksUriv3(data)
+	if e != nil {
+		return e
+	}
+
+	p.JWKs = jwks
+
+	return nil
+}
+
+// validateToken Validate the ID token, returning why it failed for the
+// metrics.
+func (p *Provider) validateToken(token *Token) (string, error) {
+	if token == nil {
+		return sso.TokenReasonMalformed, fmt.Errorf("%v", stderr.ValidateTokenNil)
+	}
+
+	info, e1 := token.IDTokenInfo()
+	if e1 != nil {
+		return sso.TokenReasonMalformed, fmt.Errorf(stderr.ParsingIDToken, e1.Error())
+	}
+
+	if p.JWKs == nil {
+		return sso.TokenReasonNoKeys, fmt.Errorf("%v", stderr.NoCerts)
+	}
+
+	// 1. Verify that the ID token is properly signed by the issuer.
+	if e := sso.VerifySignature(token.IDToken, info.Header, p.JWKs, p.DiscoveryDoc.JwksUri, p.refreshCertificate); e != nil {
+		return sso.TokenReasonSignature, fmt.Errorf(stderr.SignatureVerify, e.Error())
+	}
+
+	// 2. Verify that the iss claim exactly matches the issuer.
+	iss, ok1 := info.Payload["iss"].(string)
+	if p.ValidateIssuer != nil {
+		if e := p.ValidateIssuer(iss, info.Payload); e != nil {
+			return sso.TokenReasonIssuer, e
+		}
+	} else if !ok1 || p.DiscoveryDoc.Issuer != iss {
+		return sso.TokenReasonIssuer, fmt.Errorf(stderr.ValidateTokenIss, iss)
+	}
+
+	claims, e2 := token.Claims()
+	if e2 != nil {
+		return sso.TokenReasonMalformed, fmt.Errorf(stderr.ParsingIDToken, e2.Error())
+	}
+
+	// 3. Verify that the aud claim contains the client ID, and when there
+	// are multiple audiences the azp claim is the client ID.
+	if !claims.Audience.Contains(p.OAuth2.ClientID) {
+		return sso.TokenReasonAudience, fmt.Errorf(stderr.ValidateTokenAud, claims.Audience, p.OAuth2.ClientID)
+	}
+	if azp := claims.AuthorizedParty; azp != "" && azp != p.OAuth2.ClientID {
+		return sso.TokenReasonAuthorizedParty, fmt.Errorf(stderr.ValidateTokenAzp, azp, p.OAuth2.ClientID)
+	}
+
+	// 4. Verify that the current time is before the exp claim.
+	if time.Unix(claims.Expires, 0).Before(time.Now()) {
+		return sso.TokenReasonExpired, fmt.Errorf("%v", stderr.ValidateTokenExp)
+	}
+
+	return "", nil
+}
@@ -0,0 +1,246 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/sso"
+	"github.com/kohirens/sso/internal/ssotest"
+	"github.com/kohirens/stdlib/test"
+	"github.com/kohirens/www/storage"
+)
+
+const (
+	fixtureDir = "testdata"
+	tmpDir     = "tmp"
+	fixIssuer  = "https://idp.example.com/realms/test"
+	fixNonce   = "n-0123456789"
+	fixState   = "abcdefghijklmnopqrstuvwxyz1234"
+)
+
+func TestMain(m *testing.M) {
+	test.ResetDir(tmpDir, 0777)
+
+	os.Exit(m.Run())
+}
+
+// fixtureClient Serve the discovery document from the testdata directory,
+// the shared JWKs, and the token passed in from the token endpoint.
+func fixtureClient(token string, requests *[]*http.Request) *test.MockHttpClient {
+	dd, _ := os.ReadFile(fixtureDir + "/discovery_document.json")
+
+	return ssotest.Client(map[string]string{
+		"/.well-known/openid-configuration": string(dd),
+		"/certs":                            string(ssotest.Certificate),
+		"/token":                            token,
+	}, requests)
+}
+
+func fixtureOAuth2() *OAuth2 {
+	return &OAuth2{
+		ClientID:     "test-client",
+		ClientSecret: "1234",
+		Issuer:       fixIssuer,
+		RedirectURI:  "https://example.com/callback",
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	store, _ := storage.NewLocalStorage(tmpDir)
+
+	tests := []struct {
+		name    string
+		oauth2  *OAuth2
+		wantErr bool
+	}{
+		{"no_credentials", nil, true},
+		{"issuer_mismatch", &OAuth2{Issuer: "https://idp.example.com/realms/other"}, true},
+		{"good", fixtureOAuth2(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProvider("test_"+tt.name, tt.oauth2, fixtureClient("", nil), store, nil, "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewProvider() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if p.DiscoveryDoc.Issuer != fixIssuer || len(p.JWKs.Keys) != 1 {
+				t.Errorf("NewProvider() did not load the discovery document and keys")
+				return
+			}
+
+			if !store.Exist("test_good_discovery_document.json") || !store.Exist("test_good_certificate.json") {
+				t.Errorf("NewProvider() did not cache the discovery document and keys")
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateToken(t *testing.T) {
+	exp := time.Now().Add(5 * time.Minute).Unix()
+	tests := []struct {
+		name    string
+		payload jwt.ClaimSet
+		wantErr bool
+	}{
+		{
+			"good",
+			jwt.ClaimSet{"iss": fixIssuer, "aud": "test-client", "exp": exp, "sub": "s1"},
+			false,
+		},
+		{
+			"aud_array",
+			jwt.ClaimSet{"iss": fixIssuer, "aud": []string{"account", "test-client"}, "azp": "test-client", "exp": exp, "sub": "s1"},
+			false,
+		},
+		{
+			"wrong_azp",
+			jwt.ClaimSet{"iss": fixIssuer, "aud": []string{"account", "test-client"}, "azp": "account", "exp": exp, "sub": "s1"},
+			true,
+		},
+		{
+			"wrong_iss",
+			jwt.ClaimSet{"iss": "https://idp.example.com/realms/other", "aud": "test-client", "exp": exp, "sub": "s1"},
+			true,
+		},
+		{
+			"expired",
+			jwt.ClaimSet{"iss": fixIssuer, "aud": "test-client", "exp": time.Now().Add(-time.Minute).Unix(), "sub": "s1"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := storage.NewLocalStorage(tmpDir)
+			p, _ := NewProvider("test", fixtureOAuth2(), fixtureClient("", nil), store, nil, "")
+
+			if err := p.ValidateToken(&Token{IDToken: ssotest.IDToken(tt.payload)}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_ExchangeCodeForToken(t *testing.T) {
+	idToken := ssotest.IDToken(jwt.ClaimSet{
+		"iss":   fixIssuer,
+		"aud":   "test-client",
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"sub":   "s1",
+		"email": "test@example.com",
+		"nonce": fixNonce,
+	})
+	tokenRes := `{"access_token":"a1","expires_in":300,"id_token":"` + idToken + `","refresh_token":"r1","token_type":"Bearer"}`
+
+	tests := []struct {
+		name        string
+		authMethods []string
+		pkce        sso.PKCEMode
+		authLink    bool
+		nonce       string
+		wantBasic   bool
+		wantErr     bool
+	}{
+		{"client_secret_post", nil, sso.PKCEAuto, true, fixNonce, false, false},
+		{"client_secret_basic", []string{"client_secret_basic"}, sso.PKCEAuto, true, fixNonce, true, false},
+		{"no_code_verifier", nil, sso.PKCEAuto, false, fixNonce, false, true},
+		{"pkce_disabled", nil, sso.PKCEDisabled, false, fixNonce, false, false},
+		{"nonce_mismatch", nil, sso.PKCEDisabled, false, "n-9876543210", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []*http.Request
+			store, _ := storage.NewLocalStorage(tmpDir)
+			session := ssotest.Session{}
+			p, _ := NewProvider("test", fixtureOAuth2(), fixtureClient(tokenRes, &requests), store, session, "")
+			p.State = fixState
+			p.PKCE = tt.pkce
+			if tt.authMethods != nil {
+				p.DiscoveryDoc.TokenEndpointAuthMethodsSupported = tt.authMethods
+			}
+
+			var challenge string
+			if tt.authLink {
+				link, _ := p.AuthLink("")
+				u, _ := url.Parse(link)
+				challenge = u.Query().Get("code_challenge")
+			}
+			_ = sso.SaveState(session, p.sessionKey(sso.SessionState), fixState)
+			session.Set(p.sessionKey(sso.SessionNonce), []byte(tt.nonce))
+
+			err := p.ExchangeCodeForToken(fixState, "c1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExchangeCodeForToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			last := requests[len(requests)-1]
+			_ = last.ParseForm()
+			if verifier := last.PostForm.Get("code_verifier"); tt.authLink && sso.CodeChallengeS256(verifier) != challenge {
+				t.Errorf("ExchangeCodeForToken() code_verifier %q does not match the challenge %q", verifier, challenge)
+				return
+			}
+
+			if len(session) != 0 {
+				t.Errorf("ExchangeCodeForToken() left the code verifier or nonce in the session")
+				return
+			}
+
+			_, _, gotBasic := last.BasicAuth()
+			if gotBasic != tt.wantBasic {
+				t.Errorf("ExchangeCodeForToken() basic auth = %v, want %v", gotBasic, tt.wantBasic)
+				return
+			}
+
+			if p.ClientID() != "s1" || p.ClientEmail() != "test@example.com" {
+				t.Errorf("ExchangeCodeForToken() did not set the token")
+			}
+		})
+	}
+}
+
+func TestProvider_LoginInfo(t *testing.T) {
+	_ = os.MkdirAll(tmpDir+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmpDir)
+
+	p := &Provider{
+		Token: &Token{
+			info: &jwt.Info{Payload: jwt.ClaimSet{"sub": "register-login-info-good", "email": "test@example.com"}},
+		},
+		Login: sso.Login{Store: store},
+		name:  "test",
+	}
+
+	li, e1 := p.RegisterLoginInfo("a1", "4321", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+	if e1 != nil {
+		t.Errorf("RegisterLoginInfo() error = %v", e1)
+		return
+	}
+
+	if e := p.UpdateLoginInfo(p.DeviceID(), "5678", ""); e != nil {
+		t.Errorf("UpdateLoginInfo() error = %v", e)
+		return
+	}
+
+	got, e2 := p.LoadLoginInfo(p.DeviceID(), "5678", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+	if e2 != nil || got.AccountID != li.AccountID || got.Devices[p.DeviceID()].SessionID != "5678" {
+		t.Errorf("LoadLoginInfo() error = %v", e2)
+	}
+
+	if got.Devices[p.DeviceID()].OIDCProvider != "test" {
+		t.Errorf("RegisterLoginInfo() device provider = %v", got.Devices[p.DeviceID()].OIDCProvider)
+	}
+}
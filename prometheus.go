@@ -0,0 +1,223 @@
+package sso
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets The upper bounds of the histogram buckets, in seconds, for
+// the latency of HTTP requests.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricHelp The HELP line of the metrics recorded by the library.
+var metricHelp = map[string]string{
+	MetricHTTPDuration:            "Seconds each HTTP attempt took.",
+	MetricHTTPFailures:            "HTTP requests that ran out of attempts.",
+	MetricHTTPRetries:             "HTTP attempts made after the first.",
+	MetricTokenDuration:           "Seconds a request to the token endpoint took.",
+	MetricTokenValidationFailures: "ID tokens that failed validation.",
+	MetricTokenValidations:        "ID tokens validated.",
+}
+
+// Prometheus A MetricsRecorder that keeps the metrics in memory, and writes
+// them in the Prometheus text format, without depending on the Prometheus
+// client. Serve it on a metrics endpoint for Prometheus to scrape.
+type Prometheus struct {
+	// Buckets The upper bounds of the histogram buckets, in ascending order.
+	Buckets    []float64
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+	mu         sync.Mutex
+}
+
+// histogram The observations in each bucket, the last is +Inf.
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// NewPrometheus Make a Prometheus exporter, with DefaultBuckets when no
+// buckets are given.
+func NewPrometheus(buckets ...float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	return &Prometheus{
+		Buckets:    buckets,
+		counters:   map[string]map[string]float64{},
+		histograms: map[string]map[string]*histogram{},
+	}
+}
+
+// Add Increase a counter by the value.
+func (p *Prometheus) Add(name string, labels Labels, value float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.counters[name] == nil {
+		p.counters[name] = map[string]float64{}
+	}
+
+	p.counters[name][formatLabels(labels)] += value
+}
+
+// Observe Record a value in a histogram.
+func (p *Prometheus) Observe(name string, labels Labels, value float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.histograms[name] == nil {
+		p.histograms[name] = map[string]*histogram{}
+	}
+
+	key := formatLabels(labels)
+	h, ok := p.histograms[name][key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.Buckets)+1)}
+		p.histograms[name][key] = h
+	}
+
+	i := sort.SearchFloat64s(p.Buckets, value)
+	h.counts[i]++
+	h.sum += value
+}
+
+// ServeHTTP Respond with the metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// WriteTo Write the metrics in the Prometheus text format, sorted by name and
+// then by labels.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+
+	names := make([]string, 0, len(p.counters)+len(p.histograms))
+	for name := range p.counters {
+		names = append(names, name)
+	}
+	for name := range p.histograms {
+		if _, ok := p.counters[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if series, ok := p.counters[name]; ok {
+			writeHeader(buf, name, "counter")
+			for _, key := range sortedKeys(series) {
+				fmt.Fprintf(buf, "%v%v %v\n", name, braces(key), formatFloat(series[key]))
+			}
+		}
+
+		if series, ok := p.histograms[name]; ok {
+			writeHeader(buf, name, "histogram")
+			for _, key := range sortedKeys(series) {
+				p.writeHistogram(buf, name, key, series[key])
+			}
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// writeHistogram Write the cumulative buckets, sum, and count of a histogram.
+func (p *Prometheus) writeHistogram(buf *bytes.Buffer, name, key string, h *histogram) {
+	var total uint64
+	for i, n := range h.counts {
+		total += n
+
+		le := math.Inf(1)
+		if i < len(p.Buckets) {
+			le = p.Buckets[i]
+		}
+
+		fmt.Fprintf(buf, "%v_bucket%v %v\n", name, braces(joinLabels(key, `le="`+formatFloat(le)+`"`)), total)
+	}
+
+	fmt.Fprintf(buf, "%v_sum%v %v\n", name, braces(key), formatFloat(h.sum))
+	fmt.Fprintf(buf, "%v_count%v %v\n", name, braces(key), total)
+}
+
+// braces Put the labels in braces, nothing when there are none.
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+// formatFloat Format a value the way Prometheus parses it.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatLabels Format the labels sorted by name, with the values escaped,
+// which is also the key of the series.
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// joinLabels Append a label to formatted labels.
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+
+	return labels + "," + label
+}
+
+// labelEscaper Escape a label value for the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sortedKeys The keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// writeHeader Write the HELP and TYPE lines of a metric.
+func writeHeader(buf *bytes.Buffer, name, kind string) {
+	if help, ok := metricHelp[name]; ok {
+		fmt.Fprintf(buf, "# HELP %v %v\n", name, help)
+	}
+
+	fmt.Fprintf(buf, "# TYPE %v %v\n", name, kind)
+}
@@ -0,0 +1,92 @@
+package sso
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrometheus_WriteTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(p *Prometheus)
+		want   string
+	}{
+		{
+			"empty",
+			func(p *Prometheus) {},
+			"",
+		},
+		{
+			"counter",
+			func(p *Prometheus) {
+				p.Add(MetricTokenValidations, Labels{"provider": "google"}, 1)
+				p.Add(MetricTokenValidations, Labels{"provider": "google"}, 2)
+				p.Add(MetricTokenValidations, Labels{"provider": "apple"}, 1)
+			},
+			`# HELP sso_token_validations_total ID tokens validated.
+# TYPE sso_token_validations_total counter
+sso_token_validations_total{provider="apple"} 1
+sso_token_validations_total{provider="google"} 3
+`,
+		},
+		{
+			"histogram",
+			func(p *Prometheus) {
+				p.Observe("latency", Labels{"status": "200", "endpoint": "https://idp.example.com/token"}, 0.05)
+				p.Observe("latency", Labels{"status": "200", "endpoint": "https://idp.example.com/token"}, 0.5)
+				p.Observe("latency", Labels{"status": "200", "endpoint": "https://idp.example.com/token"}, 3)
+			},
+			`# TYPE latency histogram
+latency_bucket{endpoint="https://idp.example.com/token",status="200",le="0.1"} 1
+latency_bucket{endpoint="https://idp.example.com/token",status="200",le="1"} 2
+latency_bucket{endpoint="https://idp.example.com/token",status="200",le="+Inf"} 3
+latency_sum{endpoint="https://idp.example.com/token",status="200"} 3.55
+latency_count{endpoint="https://idp.example.com/token",status="200"} 3
+`,
+		},
+		{
+			"escaped_labels",
+			func(p *Prometheus) {
+				p.Add("errors", Labels{"reason": "say \"hi\"\n\\"}, 1)
+				p.Add("errors", nil, 1)
+			},
+			`# TYPE errors counter
+errors 1
+errors{reason="say \"hi\"\n\\"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrometheus(0.1, 1)
+			tt.record(p)
+
+			buf := &bytes.Buffer{}
+			if _, err := p.WriteTo(buf); err != nil {
+				t.Errorf("WriteTo() error = %v", err)
+				return
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheus_ServeHTTP(t *testing.T) {
+	p := NewPrometheus()
+	p.Add(MetricHTTPRetries, Labels{"endpoint": "https://idp.example.com/token"}, 1)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := w.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("ServeHTTP() Content-Type = %v", got)
+	}
+
+	if !bytes.Contains(w.Body.Bytes(), []byte(`sso_http_retries_total{endpoint="https://idp.example.com/token"} 1`)) {
+		t.Errorf("ServeHTTP() = %v", w.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package sso
+
+import (
+	"time"
+)
+
+// RetentionPolicy Which devices to drop from the login info, so that it does
+// not grow forever.
+type RetentionPolicy struct {
+	// MaxDevices The most devices to keep, the least recently active are
+	// dropped first, zero to keep any number.
+	MaxDevices int
+	// MaxIdle How long a device can go without activity before it is
+	// dropped, zero to keep idle devices.
+	MaxIdle time.Duration
+	// OnEvict Called with the devices that were dropped, when there are
+	// any, such as to tell the user or to audit.
+	OnEvict func(li *LoginInfo, evicted []*Device)
+}
+
+// Retention The policy applied each time a provider saves login info, it is
+// nil by default, which keeps every device. Set it to opt in, such as:
+//
+//	sso.Retention = &sso.RetentionPolicy{
+//		MaxDevices: 50,
+//		MaxIdle:    180 * 24 * time.Hour,
+//	}
+var Retention *RetentionPolicy
+
+// Apply Drop the devices the policy does not keep, and return them. The
+// device being kept, such as the one signing in, is never dropped. A device
+// with no activity recorded is only dropped to stay under MaxDevices.
+func (r *RetentionPolicy) Apply(li *LoginInfo, keep string, now time.Time) []*Device {
+	if r == nil || li == nil {
+		return nil
+	}
+
+	var evicted []*Device
+
+	if r.MaxIdle > 0 {
+		for id, d := range li.Devices {
+			if id != keep && !d.LastActivity.IsZero() && now.Sub(d.LastActivity) > r.MaxIdle {
+				evicted = append(evicted, d)
+				delete(li.Devices, id)
+			}
+		}
+	}
+
+	if r.MaxDevices > 0 && len(li.Devices) > r.MaxDevices {
+		devices := li.ListDevices()
+		// Least recently active are at the end.
+		for i := len(devices) - 1; i >= 0 && len(li.Devices) > r.MaxDevices; i-- {
+			if devices[i].ID == keep {
+				continue
+			}
+			evicted = append(evicted, devices[i])
+			delete(li.Devices, devices[i].ID)
+		}
+	}
+
+	if len(evicted) > 0 {
+		Log.Infof(stdout.DevicesEvicted, len(evicted), li.AccountID)
+		if r.OnEvict != nil {
+			r.OnEvict(li, evicted)
+		}
+	}
+
+	return evicted
+}
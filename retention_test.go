@@ -0,0 +1,70 @@
+package sso
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicy_Apply(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		policy      *RetentionPolicy
+		keep        string
+		wantEvicted []string
+	}{
+		{"none", nil, "", nil},
+		{"idle", &RetentionPolicy{MaxIdle: 30 * day}, "", []string{"d1", "d2"}},
+		{"idle_keep_current", &RetentionPolicy{MaxIdle: 30 * day}, "d1", []string{"d2"}},
+		{"max_devices", &RetentionPolicy{MaxDevices: 2}, "", []string{"d0", "d1", "d2"}},
+		{"max_devices_keep_current", &RetentionPolicy{MaxDevices: 2}, "d1", []string{"d0", "d2", "d3"}},
+		{"idle_and_max_devices", &RetentionPolicy{MaxDevices: 2, MaxIdle: 30 * day}, "", []string{"d0", "d1", "d2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			li := &LoginInfo{Devices: map[string]*Device{}}
+			// d0 has no activity recorded, d1 is the least recently active.
+			for i, age := range []time.Duration{-1, 90 * day, 60 * day, 10 * day, 5 * day} {
+				d := NewDeviceWithID("d"+string(rune('0'+i)), "", "", "google")
+				d.LastActivity = now.Add(-age)
+				if age < 0 {
+					d.LastActivity = time.Time{}
+				}
+				li.Devices[d.ID] = d
+			}
+
+			var reported []*Device
+			if tt.policy != nil {
+				tt.policy.OnEvict = func(li *LoginInfo, evicted []*Device) { reported = evicted }
+			}
+
+			got := tt.policy.Apply(li, tt.keep, now)
+
+			var ids []string
+			for _, d := range got {
+				ids = append(ids, d.ID)
+				if _, found := li.Devices[d.ID]; found {
+					t.Errorf("Apply() returned %v, but did not drop it", d.ID)
+				}
+			}
+			sort.Strings(ids)
+
+			if len(ids) != len(tt.wantEvicted) {
+				t.Fatalf("Apply() = %v, want %v", ids, tt.wantEvicted)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantEvicted[i] {
+					t.Errorf("Apply() = %v, want %v", ids, tt.wantEvicted)
+					break
+				}
+			}
+
+			if len(reported) != len(got) {
+				t.Errorf("Apply() reported %v devices, want %v", len(reported), len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package sso
+
+import (
+	"time"
+
+	"github.com/mileusna/useragent"
+)
+
+// RiskDecision What to do with a device that signs in.
+type RiskDecision int
+
+const (
+	// RiskAllow Let the device continue with its session.
+	RiskAllow RiskDecision = iota
+	// RiskReauthenticate Have the user sign in again with the provider.
+	RiskReauthenticate
+	// RiskDeny Refuse the device, tampering is suspected.
+	RiskDeny
+)
+
+// Reasons a risk evaluator adds to its assessment.
+const (
+	RiskReasonDeviceChanged    = "device_changed"
+	RiskReasonIdle             = "idle"
+	RiskReasonIPChanged        = "ip_changed"
+	RiskReasonOSVersionChanged = "os_version_changed"
+	// RiskReasonRevoked The device has no session, as it was revoked or
+	// signed out, it always has to sign in again.
+	RiskReasonRevoked         = "revoked"
+	RiskReasonSessionMismatch = "session_mismatch"
+)
+
+func (d RiskDecision) String() string {
+	switch d {
+	case RiskAllow:
+		return "allow"
+	case RiskReauthenticate:
+		return "reauthenticate"
+	default:
+		return "deny"
+	}
+}
+
+// RiskSignals What is known about the request of a device, to compare with
+// what was saved for it.
+type RiskSignals struct {
+	Device    *Device
+	IPAddress string
+	Now       time.Time
+	SessionID string
+	UserAgent string
+}
+
+// RiskAssessment The decision of a risk evaluator, with the reasons for it.
+type RiskAssessment struct {
+	Decision RiskDecision
+	Reasons  []string
+	Score    int
+}
+
+// RiskEvaluator Decide whether a device can continue, set Risk to tune the
+// decision for a product.
+type RiskEvaluator interface {
+	Evaluate(signals *RiskSignals) *RiskAssessment
+}
+
+// RiskScorer A RiskEvaluator that adds up a score for each signal that does
+// not match the device, then decides by comparing it to the thresholds.
+type RiskScorer struct {
+	// DenyScore The least score to deny the device.
+	DenyScore int
+	// IdleAfter How long a device can go without activity before it is
+	// scored as idle, zero to never score it.
+	IdleAfter time.Duration
+	// ReauthenticateScore The least score to have the user sign in again.
+	ReauthenticateScore int
+	// Weights The score of each reason.
+	Weights map[string]int
+}
+
+// Risk The evaluator used by LookupDevice.
+var Risk RiskEvaluator = NewRiskScorer()
+
+// NewRiskScorer Make a RiskScorer with the default weights, where a different
+// browser or device is denied, as it was before risk scoring, and a session
+// that does not match is allowed unless something else changed too.
+func NewRiskScorer() *RiskScorer {
+	return &RiskScorer{
+		DenyScore:           60,
+		IdleAfter:           30 * 24 * time.Hour,
+		ReauthenticateScore: 30,
+		Weights: map[string]int{
+			RiskReasonDeviceChanged:    60,
+			RiskReasonIdle:             30,
+			RiskReasonIPChanged:        20,
+			RiskReasonOSVersionChanged: 30,
+			RiskReasonRevoked:          30,
+			RiskReasonSessionMismatch:  20,
+		},
+	}
+}
+
+// Evaluate Score the signals against the device.
+func (r *RiskScorer) Evaluate(signals *RiskSignals) *RiskAssessment {
+	ra := &RiskAssessment{}
+	d := signals.Device
+
+	add := func(reason string) {
+		ra.Reasons = append(ra.Reasons, reason)
+		ra.Score += r.Weights[reason]
+	}
+
+	revoked := d.SessionID == ""
+	if revoked {
+		add(RiskReasonRevoked)
+	} else if signals.SessionID != d.SessionID {
+		add(RiskReasonSessionMismatch)
+	}
+
+	if d.UserAgent != nil {
+		ua := useragent.Parse(signals.UserAgent)
+		if d.UserAgent.Device != ua.Device || d.UserAgent.Name != ua.Name || d.UserAgent.OS != ua.OS {
+			add(RiskReasonDeviceChanged)
+		} else if d.UserAgent.OSVersion != ua.OSVersion {
+			add(RiskReasonOSVersionChanged)
+		}
+	}
+
+	if signals.IPAddress != "" && d.IPAddress != "" && signals.IPAddress != d.IPAddress {
+		add(RiskReasonIPChanged)
+	}
+
+	now := signals.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if r.IdleAfter > 0 && !d.LastActivity.IsZero() && now.Sub(d.LastActivity) > r.IdleAfter {
+		add(RiskReasonIdle)
+	}
+
+	switch {
+	case ra.Score >= r.DenyScore:
+		ra.Decision = RiskDeny
+	case ra.Score >= r.ReauthenticateScore:
+		ra.Decision = RiskReauthenticate
+	case revoked:
+		ra.Decision = RiskReauthenticate
+	default:
+		ra.Decision = RiskAllow
+	}
+
+	return ra
+}
@@ -0,0 +1,147 @@
+package sso
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRiskScorer_Evaluate(t *testing.T) {
+	const (
+		firefox   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0"
+		firefoxUp = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0"
+		macOS     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+		macOSUp   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+		chrome    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	)
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		deviceUA   string
+		signals    *RiskSignals
+		want       RiskDecision
+		wantReason string
+	}{
+		{"same", firefox, &RiskSignals{SessionID: "s1", UserAgent: firefox, IPAddress: "192.0.2.1"}, RiskAllow, ""},
+		{"browser_update", firefox, &RiskSignals{SessionID: "s1", UserAgent: firefoxUp}, RiskAllow, ""},
+		{"new_session", firefox, &RiskSignals{SessionID: "s2", UserAgent: firefox}, RiskAllow, RiskReasonSessionMismatch},
+		{"new_session_and_ip", firefox, &RiskSignals{SessionID: "s2", UserAgent: firefox, IPAddress: "198.51.100.1"}, RiskReauthenticate, RiskReasonIPChanged},
+		{"os_update", macOS, &RiskSignals{SessionID: "s1", UserAgent: macOSUp}, RiskReauthenticate, RiskReasonOSVersionChanged},
+		{"idle", firefox, &RiskSignals{SessionID: "s1", UserAgent: firefox, Now: now.Add(31 * 24 * time.Hour)}, RiskReauthenticate, RiskReasonIdle},
+		{"other_browser", firefox, &RiskSignals{SessionID: "s1", UserAgent: chrome}, RiskDeny, RiskReasonDeviceChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDeviceWithID("d1", tt.deviceUA, "s1", "google")
+			d.IPAddress = "192.0.2.1"
+			d.LastActivity = now
+			tt.signals.Device = d
+
+			got := NewRiskScorer().Evaluate(tt.signals)
+			if got.Decision != tt.want {
+				t.Errorf("Evaluate() = %v %v, want %v", got.Decision, got.Reasons, tt.want)
+			}
+
+			if tt.wantReason != "" && !slices.Contains(got.Reasons, tt.wantReason) {
+				t.Errorf("Evaluate() reasons = %v, want %v", got.Reasons, tt.wantReason)
+			}
+		})
+	}
+}
+
+type fixedRisk RiskDecision
+
+func (r fixedRisk) Evaluate(signals *RiskSignals) *RiskAssessment {
+	return &RiskAssessment{Decision: RiskDecision(r), Reasons: []string{"fixed"}}
+}
+
+func TestLoginInfo_LookupDevice(t *testing.T) {
+	tests := []struct {
+		name       string
+		risk       RiskEvaluator
+		wantDevice bool
+		wantErr    bool
+	}{
+		{"allow", fixedRisk(RiskAllow), true, false},
+		{"reauthenticate", fixedRisk(RiskReauthenticate), true, true},
+		{"deny", fixedRisk(RiskDeny), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(r RiskEvaluator) { Risk = r }(Risk)
+			Risk = tt.risk
+
+			got, err := fixtureLoginInfo().LookupDevice("d1", "s-d1", "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LookupDevice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if (got != nil) != tt.wantDevice {
+				t.Errorf("LookupDevice() = %v, want a device %v", got, tt.wantDevice)
+			}
+		})
+	}
+}
+
+func TestLoginInfo_LookupDevice_Revoked(t *testing.T) {
+	tests := []struct {
+		name   string
+		weight int
+	}{
+		{"default", 30},
+		{"no_weight", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(r RiskEvaluator) { Risk = r }(Risk)
+			scorer := NewRiskScorer()
+			scorer.Weights[RiskReasonRevoked] = tt.weight
+			Risk = scorer
+
+			li := fixtureLoginInfo()
+			_ = li.RevokeDevice("d1")
+
+			_, err := li.LookupDevice("d1", "s-d1", "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0")
+			var reauthenticate *ErrReauthenticate
+			if !errors.As(err, &reauthenticate) || !slices.Contains(reauthenticate.Reasons, RiskReasonRevoked) {
+				t.Errorf("LookupDevice() error = %v, want ErrReauthenticate for a revoked device", err)
+			}
+		})
+	}
+}
+
+func TestLoginInfo_LookupDeviceWithIP(t *testing.T) {
+	const ua = "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0"
+
+	tests := []struct {
+		name      string
+		sessionID string
+		ipAddress string
+		wantErr   bool
+	}{
+		{"same_ip", "s-d1", "192.0.2.1", false},
+		{"new_ip", "s-d1", "198.51.100.1", false},
+		{"new_session", "s2", "192.0.2.1", false},
+		{"new_session_and_ip", "s2", "198.51.100.1", true},
+		{"no_ip", "s2", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			li := fixtureLoginInfo()
+			li.Devices["d1"].IPAddress = "192.0.2.1"
+
+			_, err := li.LookupDeviceWithIP("d1", tt.sessionID, ua, tt.ipAddress)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LookupDeviceWithIP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var reauthenticate *ErrReauthenticate
+			if tt.wantErr && (!errors.As(err, &reauthenticate) || !slices.Contains(reauthenticate.Reasons, RiskReasonIPChanged)) {
+				t.Errorf("LookupDeviceWithIP() error = %v, want ErrReauthenticate for %v", err, RiskReasonIPChanged)
+			}
+		})
+	}
+}
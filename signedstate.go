@@ -0,0 +1,157 @@
+package sso
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ReturnURLs Where a user may be sent back to after login, to prevent the
+// state being used for an open redirect.
+type ReturnURLs struct {
+	// Hosts Allowed in an absolute URL. Relative paths stay on the host of
+	// the application, so they are always allowed.
+	Hosts []string
+	// Paths The allowed paths, along with everything under them, any path is
+	// allowed when empty. "/app" allows "/app" and "/app/settings", but not
+	// "/application".
+	Paths []string
+}
+
+// signedState The content of a signed state.
+type signedState struct {
+	Token     string `json:"token"`
+	IssuedAt  int64  `json:"iat"`
+	ReturnURL string `json:"url,omitempty"`
+}
+
+// Allowed Check the return URL is a relative path or an HTTPS URL on an
+// allowed host, with an allowed path. The path is cleaned first, so that dot
+// segments cannot climb out of an allowed path.
+func (r *ReturnURLs) Allowed(returnURL string) bool {
+	// Reject protocol-relative URLs and backslashes that browsers treat as
+	// slashes, they point to another host.
+	if strings.HasPrefix(returnURL, "//") || strings.Contains(returnURL, "\\") {
+		return false
+	}
+
+	u, e1 := url.Parse(returnURL)
+	if e1 != nil || u.User != nil {
+		return false
+	}
+
+	if u.IsAbs() || u.Host != "" {
+		if u.Scheme != "https" || !hasHost(r.Hosts, u.Hostname()) {
+			return false
+		}
+	} else if !strings.HasPrefix(u.Path, "/") {
+		return false
+	}
+
+	if len(r.Paths) == 0 {
+		return true
+	}
+
+	clean := path.Clean("/" + u.Path)
+	for _, p := range r.Paths {
+		if hasPath(clean, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewSignedState Generate an anti-forgery state that carries the URL to
+// return the user to after login. It is signed with HMAC-SHA256 using the
+// key, so that it cannot be tampered with. Set it as the State of a provider
+// before calling AuthLink, the provider verifies it in the callback as any
+// other state, then call ParseSignedState to get the URL back.
+func NewSignedState(key []byte, returnURL string) (string, error) {
+	if len(key) == 0 {
+		return "", fmt.Errorf("%v", stderr.NoStateKey)
+	}
+
+	data, e1 := json.Marshal(&signedState{
+		Token:     NewState(),
+		IssuedAt:  time.Now().Unix(),
+		ReturnURL: returnURL,
+	})
+	if e1 != nil {
+		return "", fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + sign(key, payload), nil
+}
+
+// ParseSignedState Verify the signature and age of a state made with
+// NewSignedState, then return the URL it carries. The return URL is only
+// given when the allowlist permits it.
+func ParseSignedState(key []byte, state string, allow *ReturnURLs) (string, error) {
+	if len(key) == 0 {
+		return "", fmt.Errorf("%v", stderr.NoStateKey)
+	}
+
+	payload, sig, found := strings.Cut(state, ".")
+	if !found || !hmac.Equal([]byte(sig), []byte(sign(key, payload))) {
+		return "", &ErrStateSignature{}
+	}
+
+	data, e1 := base64.RawURLEncoding.DecodeString(payload)
+	if e1 != nil {
+		return "", fmt.Errorf(stderr.DecodeBase64URL, e1.Error())
+	}
+
+	ss := &signedState{}
+	if e := json.Unmarshal(data, ss); e != nil {
+		return "", fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	if time.Since(time.Unix(ss.IssuedAt, 0)) > StateExpiry {
+		return "", &ErrStateExpired{}
+	}
+
+	if ss.ReturnURL == "" {
+		return "", nil
+	}
+
+	if allow == nil || !allow.Allowed(ss.ReturnURL) {
+		return "", &ErrReturnURLNotAllowed{ss.ReturnURL}
+	}
+
+	return ss.ReturnURL, nil
+}
+
+// hasHost Check for a host, ignoring case.
+func hasHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPath Check the path is the allowed path, or under it.
+func hasPath(clean, allowed string) bool {
+	allowed = strings.TrimSuffix(allowed, "/")
+
+	return clean == allowed || strings.HasPrefix(clean, allowed+"/")
+}
+
+// sign Compute the HMAC-SHA256 signature of the payload.
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
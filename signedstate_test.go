@@ -0,0 +1,88 @@
+package sso
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReturnURLs_Allowed(t *testing.T) {
+	allow := &ReturnURLs{
+		Hosts: []string{"app.example.com"},
+		Paths: []string{"/account", "/docs/"},
+	}
+
+	tests := []struct {
+		name      string
+		returnURL string
+		want      bool
+	}{
+		{"relative_path", "/account/settings?tab=2", true},
+		{"path_not_allowed", "/admin", false},
+		{"exact_path", "/account", true},
+		{"path_prefix_of_word", "/accountant/settings", false},
+		{"dot_segments", "/account/../admin", false},
+		{"encoded_dot_segments", "/account/%2e%2e/admin", false},
+		{"dot_segments_within", "/account/./settings", true},
+		{"allowed_host", "https://app.example.com/docs/intro", true},
+		{"host_case", "https://APP.example.com/account", true},
+		{"other_host", "https://evil.example.com/account", false},
+		{"http_scheme", "http://app.example.com/account", false},
+		{"protocol_relative", "//evil.example.com/account", false},
+		{"backslash", "/\\evil.example.com/account", false},
+		{"user_info", "https://app.example.com@evil.example.com/account", false},
+		{"no_leading_slash", "account", false},
+		{"javascript", "javascript:alert(1)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allow.Allowed(tt.returnURL); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.returnURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSignedState(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	allow := &ReturnURLs{Paths: []string{"/account"}}
+
+	good, _ := NewSignedState(key, "/account/settings")
+	noURL, _ := NewSignedState(key, "")
+	notAllowed, _ := NewSignedState(key, "https://evil.example.com/")
+	payload, sig, _ := strings.Cut(good, ".")
+
+	tests := []struct {
+		name    string
+		key     []byte
+		state   string
+		expiry  time.Duration
+		want    string
+		wantErr bool
+	}{
+		{"good", key, good, StateExpiry, "/account/settings", false},
+		{"no_return_url", key, noURL, StateExpiry, "", false},
+		{"not_allowed", key, notAllowed, StateExpiry, "", true},
+		{"wrong_key", []byte("fedcba9876543210"), good, StateExpiry, "", true},
+		{"no_key", nil, good, StateExpiry, "", true},
+		{"tampered", key, payload + "x." + sig, StateExpiry, "", true},
+		{"unsigned", key, payload, StateExpiry, "", true},
+		{"expired", key, good, -time.Minute, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(d time.Duration) { StateExpiry = d }(StateExpiry)
+			StateExpiry = tt.expiry
+
+			got, err := ParseSignedState(tt.key, tt.state, allow)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSignedState() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseSignedState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
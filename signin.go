@@ -0,0 +1,370 @@
+package sso
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kohirens/www/storage"
+	"github.com/mileusna/useragent"
+)
+
+// Identity Who a provider signed in, as far as its token tells.
+type Identity struct {
+	// Claims The claims of the ID token, nil for providers without one, such
+	// as GitHub.
+	Claims *IDTokenClaims
+	Email  string
+	// FamilyName and GivenName The name of the user, when the provider sends
+	// it apart from the token, such as Apple.
+	FamilyName string
+	GivenName  string
+	// Location Where the login info of the identity is kept in storage.
+	Location     string
+	Provider     string
+	RefreshToken string
+	Subject      string
+}
+
+// Login The login info of the client signed in with a provider, and the
+// device they are on. Providers embed it for the steps they all share, so
+// that each one only talks to its identity provider, and passes who it
+// signed in as an Identity.
+type Login struct {
+	// Audit Records logins, failures, and device changes, nothing is
+	// recorded when nil.
+	Audit Auditor `json:"-"`
+	// Cipher Encrypts the login info before it is saved, as it holds PII and
+	// the refresh token. Plain JSON is saved when nil.
+	Cipher Cipher `json:"-"`
+	// Hooks Called at each step of the login lifecycle, a hook can veto the
+	// step with an error.
+	Hooks Hooks `json:"-"`
+	// Store Where the login info is kept.
+	Store     storage.Storage `json:"-"`
+	deviceID  string
+	ipAddress string
+	loginInfo *LoginInfo
+}
+
+// AddDevice Add a device the client has not signed in with before to the
+// login info, then save it.
+func (l *Login) AddDevice(id *Identity, deviceID, sessionID, userAgent string) error {
+	if l.loginInfo == nil {
+		return &ErrNoLoginInfo{deviceID}
+	}
+
+	device := NewDeviceWithID(deviceID, userAgent, sessionID, id.Provider)
+	device.IPAddress = l.ipAddress
+	if e := l.RunHook(HookNewDevice, id, l.loginInfo, device); e != nil {
+		return e
+	}
+
+	l.loginInfo.Devices[device.ID] = device
+	l.deviceID = device.ID
+
+	if e := l.Save(id); e != nil {
+		return e
+	}
+
+	l.Record(id, AuditDeviceAdded, nil)
+
+	return nil
+}
+
+// DeviceID Get the ID of the device the user is currently logged in with.
+func (l *Login) DeviceID() string {
+	return l.deviceID
+}
+
+// LoginInfo The login info loaded or registered, nil before then.
+func (l *Login) LoginInfo() *LoginInfo {
+	return l.loginInfo
+}
+
+// Open Load the login info of the identity from storage, and look up the
+// device in it, then record the login, now that the account is known. The
+// device is not bound when Risk denies it, or asks it to sign in again, the
+// login info is returned along with an ErrDeviceDenied or ErrReauthenticate.
+func (l *Login) Open(id *Identity, deviceID, sessionID, userAgent string) (*LoginInfo, error) {
+	data, e1 := l.Store.Load(id.Location)
+	if e1 != nil { // When you cannot load it, then just make it.
+		return nil, &ErrNoLoginInfo{id.Location}
+	}
+
+	li, e2 := OpenLoginInfo(l.Cipher, data)
+	if e2 != nil {
+		return nil, e2
+	}
+
+	l.loginInfo = li
+
+	if deviceID != "" {
+		d, e := li.LookupDeviceWithIP(deviceID, sessionID, userAgent, l.ipAddress)
+		switch e.(type) {
+		case *ErrDeviceDenied, *ErrReauthenticate:
+			l.record(&AuditEvent{DeviceID: deviceID, Type: AuditLogin}, id, e)
+			return li, e
+		case nil:
+		default:
+			Log.Warnf("%v", e.Error())
+		}
+		if d != nil {
+			l.deviceID = d.ID
+		}
+	}
+
+	l.Record(id, AuditLogin, nil)
+
+	return li, nil
+}
+
+// Record Record an event about the current device, when an auditor is set.
+func (l *Login) Record(id *Identity, eventType AuditEventType, reason error) {
+	l.record(&AuditEvent{DeviceID: l.deviceID, Type: eventType}, id, reason)
+}
+
+// Register Make new login info for the identity with the device, then save
+// it and record the login. The device is added under the ID set with
+// SetDeviceID, or a random one.
+//
+//	NOTE: This is the only time the user agent is set on a device.
+func (l *Login) Register(id *Identity, accountID, sessionID, userAgent string) (*LoginInfo, error) {
+	li := &LoginInfo{
+		AccountID:    accountID,
+		Devices:      make(map[string]*Device),
+		Email:        id.Email,
+		FamilyName:   id.FamilyName,
+		GivenName:    id.GivenName,
+		ClientID:     id.Subject,
+		RefreshToken: id.RefreshToken,
+	}
+
+	device := NewDeviceWithID(l.deviceID, userAgent, sessionID, id.Provider)
+	device.IPAddress = l.ipAddress
+	li.Devices[device.ID] = device
+
+	if e := l.RunHook(HookNewDevice, id, li, device); e != nil {
+		return nil, e
+	}
+
+	l.deviceID = device.ID
+	l.loginInfo = li
+
+	if e := l.Save(id); e != nil {
+		return nil, e
+	}
+
+	l.Record(id, AuditLogin, nil)
+	l.Record(id, AuditDeviceAdded, nil)
+
+	return li, nil
+}
+
+// Revoke Sign the device out and stop trusting it, so that it must
+// authenticate again, then save the login info.
+func (l *Login) Revoke(id *Identity, deviceID string) error {
+	if l.loginInfo == nil {
+		return &ErrNoLoginInfo{deviceID}
+	}
+
+	if e := l.loginInfo.RevokeDevice(deviceID); e != nil {
+		return e
+	}
+
+	if e := l.Save(id); e != nil {
+		return e
+	}
+
+	l.record(&AuditEvent{DeviceID: deviceID, Type: AuditDeviceRevoked}, id, nil)
+
+	return nil
+}
+
+// RunHook Call the hook with what is known at this step.
+func (l *Login) RunHook(hook string, id *Identity, li *LoginInfo, device *Device) error {
+	return RunHook(l.Hooks, hook, &HookEvent{
+		Claims:    id.Claims,
+		Device:    device,
+		LoginInfo: li,
+		Provider:  id.Provider,
+	})
+}
+
+// Save Apply the Retention policy, then save the login info in the location
+// of the identity.
+func (l *Login) Save(id *Identity) error {
+	Retention.Apply(l.loginInfo, l.deviceID, time.Now())
+
+	data, e1 := SealLoginInfo(l.Cipher, l.loginInfo)
+	if e1 != nil {
+		return e1
+	}
+
+	return l.Store.Save(id.Location, data)
+}
+
+// SetDeviceID Set the ID issued to the browser, see DeviceCookie, so that
+// Register adds the device under it. A random ID is used otherwise.
+func (l *Login) SetDeviceID(deviceID string) {
+	l.deviceID = deviceID
+}
+
+// SetIPAddress Set the IP address of the request, such as the host of
+// http.Request.RemoteAddr, so that Open has Risk compare it with the one
+// saved on the device, and Register and Update save it on the device.
+func (l *Login) SetIPAddress(ipAddress string) {
+	l.ipAddress = ipAddress
+}
+
+// SetLoginInfo Use login info the application already has, such as from a
+// cache, in place of loading it.
+func (l *Login) SetLoginInfo(li *LoginInfo) {
+	l.loginInfo = li
+}
+
+// SignIn Load the login info of the identity with the device, registering it
+// on the first login, or adding the device when it is new. For logins that
+// do not go through a browser, such as the device authorization grant.
+func (l *Login) SignIn(id *Identity, accountID, deviceID, sessionID, userAgent string) (*LoginInfo, error) {
+	l.deviceID = ""
+
+	li, e1 := l.Open(id, deviceID, sessionID, userAgent)
+
+	var noLoginInfo *ErrNoLoginInfo
+	var reauthenticate *ErrReauthenticate
+	switch {
+	case errors.As(e1, &noLoginInfo):
+		l.deviceID = deviceID
+		return l.Register(id, accountID, sessionID, userAgent)
+	case errors.As(e1, &reauthenticate):
+		// The client just signed in again, so bind the device.
+		if e := l.Update(id, deviceID, sessionID, userAgent); e != nil {
+			return nil, e
+		}
+		return li, nil
+	case e1 != nil:
+		return nil, e1
+	}
+
+	if l.deviceID == "" {
+		if e := l.AddDevice(id, deviceID, sessionID, userAgent); e != nil {
+			return nil, e
+		}
+	}
+
+	return li, nil
+}
+
+// SignOut Call revoke to revoke the token with the provider, then unbind the
+// session from the device, along with the refresh token when it was revoked.
+// Pass a nil revoke when no one is signed in. The OnSignOut hook is called
+// first, its error is only returned when nothing else failed.
+func (l *Login) SignOut(id *Identity, revoke func() error) error {
+	var err error
+	revoked := false
+
+	var device *Device
+	if l.loginInfo != nil {
+		device = l.loginInfo.Devices[l.deviceID]
+	}
+	hookErr := l.RunHook(HookSignOut, id, l.loginInfo, device)
+
+	if revoke != nil {
+		if e := revoke(); e != nil {
+			err = fmt.Errorf(stderr.SignOut, e.Error())
+		} else {
+			revoked = true
+		}
+
+		if e := l.unbind(id, revoked); e != nil && err == nil {
+			err = fmt.Errorf(stderr.SignOut, e.Error())
+		}
+	}
+
+	if err == nil {
+		err = hookErr
+	}
+
+	l.Record(id, AuditSignOut, err)
+
+	return err
+}
+
+// Update Bind the session to the device, and record its activity, then save
+// the login info. The refresh token is kept unless the identity has a new
+// one.
+//
+//	NOTE: Never update the ClientID nor the user agent on the device, these
+//	are only set on registration.
+func (l *Login) Update(id *Identity, deviceID, sessionID, userAgent string) error {
+	if l.loginInfo == nil {
+		return &ErrNoLoginInfo{deviceID}
+	}
+
+	// Some providers only send a refresh token on consent, so keep the one
+	// saved.
+	if id.RefreshToken != "" {
+		l.loginInfo.RefreshToken = id.RefreshToken
+	}
+	l.loginInfo.Email = id.Email
+	// Likewise for the name, which is only sent now and then.
+	if id.FamilyName != "" || id.GivenName != "" {
+		l.loginInfo.FamilyName = id.FamilyName
+		l.loginInfo.GivenName = id.GivenName
+	}
+
+	device := l.loginInfo.Devices[deviceID]
+	if device == nil {
+		return &ErrDeviceNotFound{deviceID}
+	}
+
+	l.deviceID = device.ID
+	// Sessions are ephemeral, so we just replace them.
+	device.SessionID = sessionID
+	if device.UserAgent == nil {
+		ua := useragent.Parse(userAgent)
+		device.UserAgent = &ua
+	}
+	if l.ipAddress != "" {
+		device.IPAddress = l.ipAddress
+	}
+	device.LastActivity = time.Now()
+
+	return l.Save(id)
+}
+
+// record Fill in what is known about the client, then record the event.
+func (l *Login) record(event *AuditEvent, id *Identity, reason error) {
+	if l.Audit == nil {
+		return
+	}
+
+	event.Provider = id.Provider
+	event.Subject = id.Subject
+	if reason != nil {
+		event.Reason = reason.Error()
+	}
+	if l.loginInfo != nil {
+		event.AccountID = l.loginInfo.AccountID
+	}
+
+	if e := l.Audit.Record(event); e != nil {
+		Log.Errf("%v", e.Error())
+	}
+}
+
+// unbind Drop the session of the current device from the login info, along
+// with the refresh token when it was revoked, then save it.
+func (l *Login) unbind(id *Identity, revoked bool) error {
+	if l.loginInfo == nil || id.Location == "" {
+		return nil
+	}
+
+	l.loginInfo.UnbindDevice(l.deviceID)
+	if revoked {
+		l.loginInfo.RefreshToken = nil
+	}
+
+	return l.Save(id)
+}
@@ -0,0 +1,186 @@
+package sso
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+func TestLogin_Open(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmp)
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36"
+	id := &Identity{Location: "logins/open.json", Provider: "test", Subject: "open"}
+
+	l := &Login{Store: store}
+	idle := NewDeviceWithID("d2", firefox, "session2", "test")
+	idle.LastActivity = time.Now().Add(-60 * 24 * time.Hour)
+	l.SetLoginInfo(&LoginInfo{Devices: map[string]*Device{
+		"d1": NewDeviceWithID("d1", firefox, "session1", "test"),
+		"d2": idle,
+	}})
+	if e := l.Save(id); e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		name      string
+		deviceID  string
+		sessionID string
+		userAgent string
+		want      error
+		wantBound bool
+	}{
+		{"allow", "d1", "session1", firefox, nil, true},
+		{"unknown_device", "d3", "session3", firefox, nil, false},
+		{"reauthenticate", "d2", "session2", firefox, &ErrReauthenticate{}, false},
+		{"deny", "d1", "session1", chrome, &ErrDeviceDenied{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Login{Store: store}
+
+			li, err := l.Open(id, tt.deviceID, tt.sessionID, tt.userAgent)
+			if li == nil || fmt.Sprintf("%T", err) != fmt.Sprintf("%T", tt.want) {
+				t.Errorf("Open() error = %v, want %T", err, tt.want)
+			}
+
+			if (l.DeviceID() != "") != tt.wantBound {
+				t.Errorf("Open() bound device %q", l.DeviceID())
+			}
+		})
+	}
+}
+
+// auditRecorder Keep the events in memory.
+type auditRecorder []*AuditEvent
+
+func (a *auditRecorder) Record(event *AuditEvent) error {
+	*a = append(*a, event)
+	return nil
+}
+
+func TestLogin_SignIn(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmp)
+	ua := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	id := &Identity{Email: "crowbar@example.com", Location: "logins/s1.json", Provider: "test", RefreshToken: "r1", Subject: "s1"}
+
+	tests := []struct {
+		name        string
+		deviceID    string
+		sessionID   string
+		wantDevices int
+	}{
+		{"register", "d1", "session1", 1},
+		{"known_device", "d1", "session1", 1},
+		{"new_device", "d2", "session2", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &auditRecorder{}
+			l := &Login{Audit: audit, Store: store}
+
+			li, err := l.SignIn(id, "a1", tt.deviceID, tt.sessionID, ua)
+			if err != nil {
+				t.Fatalf("SignIn() error = %v", err)
+			}
+
+			if len(li.Devices) != tt.wantDevices || l.DeviceID() != tt.deviceID {
+				t.Errorf("SignIn() devices = %v, device ID %v", li.Devices, l.DeviceID())
+			}
+
+			if li.AccountID != "a1" || li.ClientID != "s1" {
+				t.Errorf("SignIn() login info = %v", li)
+			}
+
+			if len(*audit) == 0 || (*audit)[0].Type != AuditLogin || (*audit)[0].AccountID != "a1" {
+				t.Errorf("SignIn() did not record the login with the account")
+			}
+		})
+	}
+}
+
+func TestLogin_SignOut(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmp)
+	errRevoke := errors.New("revoke failed")
+
+	tests := []struct {
+		name        string
+		revoke      func() error
+		wantErr     bool
+		wantSession string
+		wantRefresh bool
+	}{
+		{"revoked", func() error { return nil }, false, "", false},
+		{"revoke_failed", func() error { return errRevoke }, true, "", true},
+		{"signed_out", nil, false, "session1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := &Identity{Location: "logins/" + tt.name + ".json", Provider: "test", Subject: tt.name}
+			l := &Login{Store: store}
+			l.SetDeviceID("d1")
+			l.SetLoginInfo(&LoginInfo{
+				Devices:      map[string]*Device{"d1": {ID: "d1", SessionID: "session1"}},
+				RefreshToken: "r1",
+			})
+
+			err := l.SignOut(id, tt.revoke)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignOut() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			li := l.LoginInfo()
+			if li.Devices["d1"].SessionID != tt.wantSession || (li.RefreshToken != nil) != tt.wantRefresh {
+				t.Errorf("SignOut() login info = %v", li)
+			}
+		})
+	}
+}
+
+func TestLogin_SetIPAddress(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.MkdirAll(tmp+"/logins", 0777)
+	store, _ := storage.NewLocalStorage(tmp)
+	ua := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	id := &Identity{Location: "logins/ip.json", Provider: "test", Subject: "ip"}
+
+	l1 := &Login{Store: store}
+	l1.SetIPAddress("192.0.2.1")
+	li, e1 := l1.SignIn(id, "a1", "d1", "session1", ua)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	if got := li.Devices["d1"].IPAddress; got != "192.0.2.1" {
+		t.Errorf("SignIn() device IP address = %q", got)
+		return
+	}
+
+	// Another session from another address has to sign in again.
+	l2 := &Login{Store: store}
+	l2.SetIPAddress("198.51.100.1")
+	_, e2 := l2.Open(id, "d1", "session2", ua)
+
+	var reauthenticate *ErrReauthenticate
+	if !errors.As(e2, &reauthenticate) || l2.DeviceID() != "" {
+		t.Errorf("Open() error = %v, want ErrReauthenticate", e2)
+		return
+	}
+
+	// Signing in again saves the new address.
+	if e := l2.Update(id, "d1", "session2", ua); e != nil || l2.LoginInfo().Devices["d1"].IPAddress != "198.51.100.1" {
+		t.Errorf("Update() error = %v, device = %v", e, l2.LoginInfo().Devices["d1"])
+	}
+}
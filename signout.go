@@ -0,0 +1,54 @@
+package sso
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// sessionNames Every value the library keeps in the session for a provider.
+var sessionNames = []string{
+	SessionCodeVerifier,
+	SessionNonce,
+	SessionState,
+}
+
+// ClearSession Remove every value the library keeps in the session for a
+// provider. The key function gives the session key of a value for the
+// provider.
+func ClearSession(session SessionManager, key func(name string) string) error {
+	if session == nil {
+		return nil
+	}
+
+	var err error
+	for _, name := range sessionNames {
+		if e := session.Remove(key(name)); e != nil {
+			Log.Warnf("%v", e.Error())
+			err = e
+		}
+	}
+
+	return err
+}
+
+// RevokeToken Revoke a refresh or access token at the revocation endpoint of
+// a provider, see RFC 7009. The request body holds the token, along with any
+// client credentials the provider wants.
+func RevokeToken(client HttpClient, uri, reqBody string, headers http.Header) error {
+	if uri == "" {
+		return fmt.Errorf("%v", stderr.NoRevocationURI)
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, e1 := SendWithRetry(client, "POST", uri, []byte(reqBody), headers, http.StatusOK, 3)
+	if res == nil {
+		return fmt.Errorf(stderr.Response, e1)
+	}
+	_ = res.Body.Close()
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package sso
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"github.com/kohirens/stdlib/logger"
+)
 
 type OIDCProvider interface {
 	// AuthLink Generate a link, when clicked, send the browser to where a user
@@ -26,11 +29,32 @@ type SessionManager interface {
 
 type Token interface{}
 
+// Log A logger that follows the Kohirens standard of logging.
+var Log = &logger.Standard{}
+
 const (
 	SessionTokenGoogle = "__gp__"
 	SessionTokenApple  = "__ap__"
+	SessionTokenGitHub = "__gh__"
 )
 
+// Names of the values a provider keeps in the session, prefixed with the
+// session token of the provider.
+const (
+	SessionCodeVerifier = "code_verifier"
+	SessionNonce        = "nonce"
+	SessionState        = "state"
+)
+
+// Location Where a document is kept in storage, under the prefix when there
+// is one.
+func Location(prefix, name string) string {
+	if prefix != "" {
+		return prefix + "/" + name + ".json"
+	}
+	return name + ".json"
+}
+
 // NewState Generates an anti-forgery unique session token.
 func NewState() string {
 	return uuid.New().String()
@@ -0,0 +1,92 @@
+package sso
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StateExpiry How long a login has to come back to the callback before its
+// state expires.
+var StateExpiry = 10 * time.Minute
+
+// pendingState The state of a login in progress, as kept in the session.
+type pendingState struct {
+	Value   string `json:"value"`
+	Expires int64  `json:"expires"`
+}
+
+// SaveState Keep the state of a login in the session under the key, so that
+// any request can verify the callback, until it expires.
+func SaveState(session SessionManager, key, state string) error {
+	if session == nil {
+		return &ErrNoSession{}
+	}
+
+	data, e1 := json.Marshal(&pendingState{
+		Value:   state,
+		Expires: time.Now().Add(StateExpiry).Unix(),
+	})
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	session.Set(key, data)
+
+	return nil
+}
+
+// LoadState Take the state of the pending login out of the session so that
+// it can only be used once.
+func LoadState(session SessionManager, key string) (string, error) {
+	if session == nil {
+		return "", &ErrNoSession{}
+	}
+
+	data := session.Get(key)
+	if len(data) == 0 {
+		return "", &ErrNoSessionData{key}
+	}
+
+	if e := session.Remove(key); e != nil {
+		Log.Warnf("%v", e.Error())
+	}
+
+	state := &pendingState{}
+	if e := json.Unmarshal(data, state); e != nil {
+		return "", fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	if time.Now().Unix() > state.Expires {
+		return "", &ErrStateExpired{}
+	}
+
+	return state.Value, nil
+}
+
+// VerifyState Verify the state returned to the callback matches the state of
+// the pending login, kept in the session under the key. The error is an
+// ErrInvalidState with where to send the browser.
+func VerifyState(session SessionManager, key, returnedState string) error {
+	if len(returnedState) < 30 {
+		return &ErrInvalidState{stderr.InvalidState, "/?m=invalid-state", http.StatusSeeOther}
+	}
+
+	// Load the state from the session, as the callback is handled by a
+	// different request than the one that made the link.
+	state, e1 := LoadState(session, key)
+	if e1 != nil {
+		location := "/?m=invalid-state"
+		if _, ok := e1.(*ErrStateExpired); ok {
+			location = "/?m=expired-state"
+		}
+		return &ErrInvalidState{e1.Error(), location, http.StatusSeeOther}
+	}
+
+	if returnedState != state {
+		return &ErrInvalidState{stderr.StateMismatch, "/?m=bad-state", http.StatusSeeOther}
+	}
+
+	return nil
+}
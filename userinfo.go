@@ -0,0 +1,143 @@
+package sso
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	jwt "github.com/kohirens/json-web-token"
+)
+
+// Profile The standard claims about a user, as returned by the userinfo
+// endpoint or found in the ID token, see:
+// https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+type Profile struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+	Locale        string `json:"locale,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// UnmarshalJSON Decode a profile, accepting email_verified as a string, as
+// some providers, such as Apple, send "true" rather than true.
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type profile Profile
+	aux := &struct {
+		*profile
+		EmailVerified interface{} `json:"email_verified"`
+	}{profile: (*profile)(p)}
+
+	if e := json.Unmarshal(data, aux); e != nil {
+		return e
+	}
+
+	switch v := aux.EmailVerified.(type) {
+	case bool:
+		p.EmailVerified = v
+	case string:
+		p.EmailVerified, _ = strconv.ParseBool(v)
+	}
+
+	return nil
+}
+
+// ProfileFromClaims Make a profile from the claims of an ID token.
+func ProfileFromClaims(claims jwt.ClaimSet) (*Profile, error) {
+	data, e1 := json.Marshal(claims)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	profile := &Profile{}
+	if e := json.Unmarshal(data, profile); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return profile, nil
+}
+
+// UserInfo Request the profile of the user from the userinfo endpoint with
+// the access token. Claims missing from the response are filled in from the
+// ID token claims, which are used on their own when there is no endpoint,
+// or the request fails.
+func UserInfo(client HttpClient, uri, accessToken string, claims jwt.ClaimSet) (*Profile, error) {
+	idProfile, e1 := ProfileFromClaims(claims)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	if uri == "" || accessToken == "" {
+		return idProfile, nil
+	}
+
+	profile, e2 := requestUserInfo(client, uri, accessToken)
+	if e2 != nil {
+		Log.Warnf(stdout.UserInfoFallback, e2.Error())
+		return idProfile, nil
+	}
+
+	// The sub of the userinfo response must match the ID token, or the
+	// response may have been substituted, see:
+	// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+	if profile.Subject != idProfile.Subject {
+		return nil, fmt.Errorf(stderr.UserInfoSubject, profile.Subject, idProfile.Subject)
+	}
+
+	profile.merge(idProfile)
+
+	return profile, nil
+}
+
+// merge Fill in the claims missing from the profile.
+func (p *Profile) merge(other *Profile) {
+	if p.Name == "" {
+		p.Name = other.Name
+	}
+	if p.GivenName == "" {
+		p.GivenName = other.GivenName
+	}
+	if p.FamilyName == "" {
+		p.FamilyName = other.FamilyName
+	}
+	if p.Picture == "" {
+		p.Picture = other.Picture
+	}
+	if p.Locale == "" {
+		p.Locale = other.Locale
+	}
+	if p.Email == "" {
+		p.Email = other.Email
+		p.EmailVerified = other.EmailVerified
+	}
+}
+
+// requestUserInfo Call the userinfo endpoint with the access token.
+func requestUserInfo(client HttpClient, uri, accessToken string) (*Profile, error) {
+	headers := http.Header{}
+	headers.Add("Accept", "application/json")
+	headers.Add("Authorization", "Bearer "+accessToken)
+
+	res, e1 := SendWithRetry(client, "GET", uri, nil, headers, http.StatusOK, 3)
+	if res == nil {
+		return nil, fmt.Errorf(stderr.Response, e1)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	data, e2 := io.ReadAll(res.Body)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ReadResponse, e2.Error())
+	}
+
+	profile := &Profile{}
+	if e := json.Unmarshal(data, profile); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,96 @@
+package sso
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	jwt "github.com/kohirens/json-web-token"
+	"github.com/kohirens/stdlib/test"
+)
+
+func TestUserInfo(t *testing.T) {
+	claims := jwt.ClaimSet{
+		"sub":            "s1",
+		"email":          "crowbar@example.com",
+		"email_verified": "true",
+		"name":           "Crowbar Jones",
+	}
+
+	tests := []struct {
+		name       string
+		uri        string
+		status     int
+		body       string
+		want       Profile
+		wantBearer bool
+		wantErr    bool
+	}{
+		{
+			"no_endpoint",
+			"",
+			200,
+			"",
+			Profile{Subject: "s1", Name: "Crowbar Jones", Email: "crowbar@example.com", EmailVerified: true},
+			false,
+			false,
+		},
+		{
+			"merged_with_id_token",
+			"https://idp.example.com/userinfo",
+			200,
+			`{"sub":"s1","given_name":"Crowbar","family_name":"Jones","picture":"https://idp.example.com/p.png","locale":"en"}`,
+			Profile{Subject: "s1", Name: "Crowbar Jones", GivenName: "Crowbar", FamilyName: "Jones", Picture: "https://idp.example.com/p.png", Locale: "en", Email: "crowbar@example.com", EmailVerified: true},
+			true,
+			false,
+		},
+		{
+			"fallback_on_error",
+			"https://idp.example.com/userinfo",
+			500,
+			"",
+			Profile{Subject: "s1", Name: "Crowbar Jones", Email: "crowbar@example.com", EmailVerified: true},
+			true,
+			false,
+		},
+		{
+			"sub_mismatch",
+			"https://idp.example.com/userinfo",
+			200,
+			`{"sub":"s2","given_name":"Mallory"}`,
+			Profile{},
+			true,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBearer := false
+			client := &test.MockHttpClient{
+				DoHandler: func(r *http.Request) (*http.Response, error) {
+					gotBearer = r.Header.Get("Authorization") == "Bearer a1"
+					return &http.Response{StatusCode: tt.status, Body: io.NopCloser(bytes.NewBufferString(tt.body))}, nil
+				},
+			}
+
+			got, err := UserInfo(client, tt.uri, "a1", claims)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if gotBearer != tt.wantBearer {
+				t.Errorf("UserInfo() sent the access token = %v, want %v", gotBearer, tt.wantBearer)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if *got != tt.want {
+				t.Errorf("UserInfo() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}